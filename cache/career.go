@@ -0,0 +1,42 @@
+package cache
+
+import "time"
+
+// maxNameChanges is how many name changes are kept per career
+const maxNameChanges = 20
+
+// Career is what is known about the career page of a player. Career IDs
+// survive BattleTag changes, so it also records the names the page showed.
+type Career struct {
+	ID string `json:"careerId"`
+
+	// Name is the name last shown on the career page
+	Name string `json:"name"`
+
+	// NameChanges lists the changes of Name, oldest first
+	NameChanges []NameChange `json:"nameChanges,omitempty"`
+}
+
+// NameChange is a change of the name shown on a career page
+type NameChange struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+// SeenName records that the career page showed name. It returns whether the
+// name changed since the last time.
+func (c *Career) SeenName(name string) bool {
+	if name == "" || name == c.Name {
+		return false
+	}
+	changed := c.Name != ""
+	if changed {
+		c.NameChanges = append(c.NameChanges, NameChange{From: c.Name, To: name, At: time.Now().UTC()})
+		if len(c.NameChanges) > maxNameChanges {
+			c.NameChanges = c.NameChanges[len(c.NameChanges)-maxNameChanges:]
+		}
+	}
+	c.Name = name
+	return changed
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Domekologe/ow-api/ovrstat"
+)
+
+// entrySchemaVersion is the shape of the stats stored in entries. Increase it
+// whenever PlayerStats or PlayerStatsProfile change incompatibly, entries of
+// other versions are then treated as cache misses.
+const entrySchemaVersion = 1
+
+// gzipMagic starts every gzip stream. JSON never starts with it, so
+// compressed entries can be told apart from the formats written before.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// entry is how values are stored in Redis. Current entries are gzipped
+// JSON. Older ones are the same envelope as plain JSON without versions, or
+// the bare stats.
+type entry struct {
+	FetchedAt     time.Time       `json:"fetchedAt"`
+	SchemaVersion int             `json:"schemaVersion,omitempty"`
+	ParserVersion int             `json:"parserVersion,omitempty"`
+	Source        string          `json:"source,omitempty"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// encodeEntry wraps v in a current entry and compresses it
+func encodeEntry(v interface{}, source string) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stats: %w", err)
+	}
+	data, err = json.Marshal(entry{
+		FetchedAt:     time.Now().UTC(),
+		SchemaVersion: entrySchemaVersion,
+		ParserVersion: ovrstat.ParserVersion,
+		Source:        source,
+		Data:          data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress cache entry: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress cache entry: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeEntry reads an entry in any of the formats ever written. bare is set
+// for stats stored without an envelope, their FetchedAt is unknown.
+func decodeEntry(data []byte) (e entry, bare bool, err error) {
+	if bytes.HasPrefix(data, gzipMagic) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return entry{}, false, fmt.Errorf("failed to decompress cache entry: %w", err)
+		}
+		data, err = io.ReadAll(zr)
+		if err != nil {
+			return entry{}, false, fmt.Errorf("failed to decompress cache entry: %w", err)
+		}
+		if err := json.Unmarshal(data, &e); err != nil {
+			return entry{}, false, fmt.Errorf("failed to unmarshal cache entry: %w", err)
+		}
+		return e, false, nil
+	}
+
+	if err := json.Unmarshal(data, &e); err != nil || len(e.Data) == 0 {
+		return entry{Data: data}, true, nil
+	}
+	return e, false, nil
+}
+
+// compatible reports whether the stats of e can be decoded into the current
+// models. Entries from before versioning have the first schema.
+func (e entry) compatible() bool {
+	v := e.SchemaVersion
+	if v == 0 {
+		v = 1
+	}
+	return v == entrySchemaVersion
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Domekologe/ow-api/ovrstat"
+)
+
+func TestEntryRoundTrip(t *testing.T) {
+	stats := &ovrstat.PlayerStats{Name: "A", Ratings: make([]ovrstat.Rating, 50)}
+	plain, _ := json.Marshal(stats)
+
+	data, err := encodeEntry(stats, "scraper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) >= len(plain) {
+		t.Errorf("entry is %d bytes, stats are %d; want it compressed", len(data), len(plain))
+	}
+
+	e, bare, err := decodeEntry(data)
+	if err != nil || bare {
+		t.Fatalf("decodeEntry = %v, %v", bare, err)
+	}
+	if e.SchemaVersion != entrySchemaVersion || e.ParserVersion != ovrstat.ParserVersion || e.Source != "scraper" {
+		t.Errorf("entry = %+v; want current versions from scraper", e)
+	}
+	if time.Since(e.FetchedAt) > time.Minute {
+		t.Errorf("FetchedAt = %v; want now", e.FetchedAt)
+	}
+
+	var got ovrstat.PlayerStats
+	if err := json.Unmarshal(e.Data, &got); err != nil || got.Name != "A" {
+		t.Errorf("Data = %s, %v", e.Data, err)
+	}
+}
+
+func TestDecodeEntryLegacy(t *testing.T) {
+	fetchedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Plain JSON envelope without versions
+	e, bare, err := decodeEntry([]byte(`{"fetchedAt":"2025-01-02T03:04:05Z","data":{"name":"A"}}`))
+	if err != nil || bare || !e.FetchedAt.Equal(fetchedAt) || !e.compatible() {
+		t.Errorf("envelope: %+v, %v, %v", e, bare, err)
+	}
+
+	// Bare stats
+	e, bare, err = decodeEntry([]byte(`{"name":"A","private":false}`))
+	if err != nil || !bare || !e.compatible() || string(e.Data) != `{"name":"A","private":false}` {
+		t.Errorf("bare: %+v, %v, %v", e, bare, err)
+	}
+}
+
+func TestEntryIncompatible(t *testing.T) {
+	e := entry{SchemaVersion: entrySchemaVersion + 1}
+	if e.compatible() {
+		t.Error("entry of a newer schema is compatible")
+	}
+}
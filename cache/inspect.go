@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"bytes"
+	"time"
+)
+
+// PlayerEntries describes everything cached for one player, for admins.
+// Missing entries are nil.
+type PlayerEntries struct {
+	Complete *EntryInfo    `json:"complete"`
+	Profile  *EntryInfo    `json:"profile"`
+	Negative *NegativeInfo `json:"negative"`
+}
+
+// Empty reports whether nothing is cached for the player
+func (p *PlayerEntries) Empty() bool {
+	return p.Complete == nil && p.Profile == nil && p.Negative == nil
+}
+
+// EntryInfo describes one stats entry
+type EntryInfo struct {
+	Key  string `json:"key"`
+	Size int    `json:"size"`
+
+	FetchedAt  time.Time `json:"fetched_at"`
+	AgeSeconds int64     `json:"age_seconds"`
+	// TTLSeconds is how long the entry is kept, -1 if it doesn't expire
+	TTLSeconds int64 `json:"ttl_seconds"`
+
+	// Set for Redis entries only
+	SchemaVersion int    `json:"schema_version,omitempty"`
+	ParserVersion int    `json:"parser_version,omitempty"`
+	Source        string `json:"source,omitempty"`
+	Compressed    bool   `json:"compressed,omitempty"`
+}
+
+// NegativeInfo describes the negative entry of a player
+type NegativeInfo struct {
+	Kind       string    `json:"kind"`
+	Since      time.Time `json:"since"`
+	TTLSeconds int64     `json:"ttl_seconds"`
+}
+
+// ttlSeconds converts a remaining TTL, negative for keys without one, into
+// TTLSeconds
+func ttlSeconds(ttl time.Duration) int64 {
+	if ttl < 0 {
+		return -1
+	}
+	return int64(ttl.Seconds())
+}
+
+// inspectEntry describes the Redis entry data stored under key
+func inspectEntry(key string, data []byte, ttl time.Duration) (*EntryInfo, error) {
+	e, _, err := decodeEntry(data)
+	if err != nil {
+		return nil, err
+	}
+	info := &EntryInfo{
+		Key:           key,
+		Size:          len(data),
+		FetchedAt:     e.FetchedAt,
+		TTLSeconds:    ttlSeconds(ttl),
+		SchemaVersion: e.SchemaVersion,
+		ParserVersion: e.ParserVersion,
+		Source:        e.Source,
+		Compressed:    bytes.HasPrefix(data, gzipMagic),
+	}
+	if !e.FetchedAt.IsZero() {
+		info.AgeSeconds = int64(time.Since(e.FetchedAt).Seconds())
+	}
+	return info, nil
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+)
+
+// invalidationChannel carries the keys written to Redis, so processes that
+// keep entries in memory can drop their copies. It is relative to the key
+// prefix, like the keys in its messages.
+const invalidationChannel = "cache:invalidate"
+
+// invalidateAll in an invalidation message stands for every key
+const invalidateAll = "*"
+
+// invalidation is the message published on invalidationChannel
+type invalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys"`
+}
+
+// newOrigin returns a random ID for a RedisCache
+func newOrigin() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// publishInvalidation announces that keys changed. Failures are only logged,
+// subscribers' entries expire on their own.
+func (c *RedisCache) publishInvalidation(keys ...string) {
+	data, err := json.Marshal(invalidation{Origin: c.origin, Keys: keys})
+	if err != nil {
+		return
+	}
+	if err := c.client.Publish(c.ctx, c.key(invalidationChannel), data).Err(); err != nil {
+		log.Printf("Warning: Failed to publish cache invalidation: %v", err)
+	}
+}
+
+// subscribeInvalidations calls fn with the keys other processes changed. The
+// subscription reconnects on its own and ends when the returned function is
+// called.
+func (c *RedisCache) subscribeInvalidations(fn func(keys []string)) func() error {
+	sub := c.client.Subscribe(c.ctx, c.key(invalidationChannel))
+	go func() {
+		for msg := range sub.Channel() {
+			var inv invalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+				continue
+			}
+			if inv.Origin == c.origin {
+				continue
+			}
+			fn(inv.Keys)
+		}
+	}()
+	return sub.Close
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultKeyPrefix namespaces the keys in Redis unless configured otherwise
+const DefaultKeyPrefix = "ow"
+
+// Kinds of keys reported by ParseKey, next to NegativeNotFound and
+// NegativePrivate
+const (
+	KeyStats   = "stats"
+	KeyProfile = "profile"
+)
+
+// StatsKeyPattern matches the complete and profile stats keys of all players
+const StatsKeyPattern = "stats:*"
+
+// Keys are relative to the key prefix everywhere but on the wire to Redis,
+// where RedisCache.key prepends it.
+
+// statsKey generates the key of the complete stats of a player
+func statsKey(platform, tag string) string {
+	return fmt.Sprintf("stats:%s:%s", platform, tag)
+}
+
+// profileKey generates the key of the profile stats of a player
+func profileKey(platform, tag string) string {
+	return statsKey(platform, tag) + ":profile"
+}
+
+// negativeKey generates the key of a negative entry
+func negativeKey(kind, platform, tag string) string {
+	return fmt.Sprintf("negative:%s:%s:%s", kind, platform, tag)
+}
+
+// careerIDKey generates the key of the career ID a tag resolved to. Career
+// pages hold every platform, so it has none.
+func careerIDKey(tag string) string {
+	return "careerid:" + tag
+}
+
+// careerKey generates the key of a Career
+func careerKey(careerID string) string {
+	return "career:" + careerID
+}
+
+// entryKeyPatterns match the keys of all Store entries. Other data under
+// the prefix, such as the refresh queue, scraper runs or player lookups,
+// isn't part of the cache.
+var entryKeyPatterns = []string{StatsKeyPattern, NegativeKeyPattern(""), "careerid:*", "career:*"}
+
+// NegativeKeyPattern matches the keys of all negative entries of kind, or of
+// every kind when kind is empty
+func NegativeKeyPattern(kind string) string {
+	if kind == "" {
+		return "negative:*"
+	}
+	return "negative:" + kind + ":*"
+}
+
+// PlatformKeyPatterns match the stats and not-found keys of all players of a
+// platform
+func PlatformKeyPatterns(platform string) []string {
+	return []string{
+		"stats:" + platform + ":*",
+		"negative:" + NegativeNotFound + ":" + platform + ":*",
+	}
+}
+
+// PlayerKey is a key returned by ScanKeys split into its parts
+type PlayerKey struct {
+	// Kind is KeyStats, KeyProfile, NegativeNotFound or NegativePrivate
+	Kind     string
+	Platform string
+	Tag      string
+}
+
+// ParseKey splits a key returned by ScanKeys into its parts
+func ParseKey(key string) (PlayerKey, bool) {
+	parts := strings.Split(key, ":")
+	switch {
+	case len(parts) == 3 && parts[0] == "stats":
+		return PlayerKey{Kind: KeyStats, Platform: parts[1], Tag: parts[2]}, true
+	case len(parts) == 4 && parts[0] == "stats" && parts[3] == "profile":
+		return PlayerKey{Kind: KeyProfile, Platform: parts[1], Tag: parts[2]}, true
+	case len(parts) == 4 && parts[0] == "negative":
+		return PlayerKey{Kind: parts[1], Platform: parts[2], Tag: parts[3]}, true
+	}
+	return PlayerKey{}, false
+}
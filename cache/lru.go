@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// lru is a size bounded map whose entries expire. It evicts the least
+// recently used entry first. It is not safe for concurrent use.
+type lru struct {
+	maxEntries int
+	order      *list.List // front is the most recently used
+	items      map[string]*list.Element
+	evictions  int64
+}
+
+// lruItem is one entry of an lru
+type lruItem struct {
+	key       string
+	value     interface{}
+	fetchedAt time.Time
+	expiresAt time.Time
+}
+
+func newLRU(maxEntries int) *lru {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	return &lru{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// get returns the unexpired entry under key and marks it as used
+func (l *lru) get(key string) (*lruItem, bool) {
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := el.Value.(*lruItem)
+	if time.Now().After(item.expiresAt) {
+		l.removeElement(el)
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return item, true
+}
+
+// set stores an entry, evicting the least recently used ones beyond the bound
+func (l *lru) set(item *lruItem) {
+	if el, ok := l.items[item.key]; ok {
+		el.Value = item
+		l.order.MoveToFront(el)
+		return
+	}
+	l.items[item.key] = l.order.PushFront(item)
+	for l.order.Len() > l.maxEntries {
+		l.removeElement(l.order.Back())
+		l.evictions++
+	}
+}
+
+func (l *lru) remove(key string) {
+	if el, ok := l.items[key]; ok {
+		l.removeElement(el)
+	}
+}
+
+func (l *lru) removeElement(el *list.Element) {
+	l.order.Remove(el)
+	delete(l.items, el.Value.(*lruItem).key)
+}
+
+// keys returns the keys of all unexpired entries
+func (l *lru) keys() []string {
+	now := time.Now()
+	keys := make([]string, 0, len(l.items))
+	for key, el := range l.items {
+		if !now.After(el.Value.(*lruItem).expiresAt) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (l *lru) len() int {
+	return l.order.Len()
+}
+
+func (l *lru) flush() {
+	l.order.Init()
+	l.items = make(map[string]*list.Element)
+}
@@ -0,0 +1,291 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/Domekologe/ow-api/ovrstat"
+)
+
+// MemoryStore is an in-process Store for deployments without Redis. It holds
+// at most maxEntries entries and evicts the least recently used one first.
+// Values are kept serialized, so callers never share them.
+type MemoryStore struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	items *lru
+
+	entryTTLs
+}
+
+// NewMemoryStore creates a new in-memory store
+func NewMemoryStore(maxEntries int, ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:   ttl,
+		items: newLRU(maxEntries),
+	}
+}
+
+// set stores v under key for ttl
+func (m *MemoryStore) set(key string, v interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stats: %w", err)
+	}
+
+	now := time.Now()
+	m.mu.Lock()
+	m.items.set(&lruItem{key: key, value: data, fetchedAt: now, expiresAt: now.Add(ttl)})
+	m.mu.Unlock()
+	return nil
+}
+
+// get decodes the value stored under key into v. found is false on a cache
+// miss.
+func (m *MemoryStore) get(key string, v interface{}) (fetchedAt time.Time, found bool, err error) {
+	m.mu.Lock()
+	item, ok := m.items.get(key)
+	m.mu.Unlock()
+	if !ok {
+		return time.Time{}, false, nil
+	}
+
+	if err := json.Unmarshal(item.value.([]byte), v); err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to unmarshal cached data: %w", err)
+	}
+	return item.fetchedAt, true, nil
+}
+
+// Get retrieves cached player stats
+func (m *MemoryStore) Get(platform, tag string) (*ovrstat.PlayerStats, error) {
+	stats, _, err := m.GetEntry(platform, tag)
+	return stats, err
+}
+
+// GetEntry retrieves cached player stats and when they were fetched
+func (m *MemoryStore) GetEntry(platform, tag string) (*ovrstat.PlayerStats, time.Time, error) {
+	var stats ovrstat.PlayerStats
+	fetchedAt, found, err := m.get(statsKey(platform, tag), &stats)
+	if err != nil || !found {
+		return nil, time.Time{}, err
+	}
+	return &stats, fetchedAt, nil
+}
+
+// Set stores player stats in cache. It also records whether the profile is
+// private and clears a not-found entry.
+func (m *MemoryStore) Set(platform, tag string, stats *ovrstat.PlayerStats) error {
+	ttl := m.statsTTL(m.ttl, stats.Private)
+	if err := m.set(statsKey(platform, tag), stats, ttl); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	privateKey := negativeKey(NegativePrivate, platform, tag)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items.remove(negativeKey(NegativeNotFound, platform, tag))
+	if stats.Private {
+		m.items.set(&lruItem{key: privateKey, fetchedAt: now, expiresAt: now.Add(ttl)})
+	} else {
+		m.items.remove(privateKey)
+	}
+	return nil
+}
+
+// GetProfile retrieves cached player profile stats
+func (m *MemoryStore) GetProfile(platform, tag string) (*ovrstat.PlayerStatsProfile, error) {
+	stats, _, err := m.GetProfileEntry(platform, tag)
+	return stats, err
+}
+
+// GetProfileEntry retrieves cached player profile stats and when they were
+// fetched
+func (m *MemoryStore) GetProfileEntry(platform, tag string) (*ovrstat.PlayerStatsProfile, time.Time, error) {
+	var stats ovrstat.PlayerStatsProfile
+	fetchedAt, found, err := m.get(profileKey(platform, tag), &stats)
+	if err != nil || !found {
+		return nil, time.Time{}, err
+	}
+	return &stats, fetchedAt, nil
+}
+
+// SetProfile stores player profile stats in cache
+func (m *MemoryStore) SetProfile(platform, tag string, stats *ovrstat.PlayerStatsProfile) error {
+	return m.set(profileKey(platform, tag), stats, m.statsTTL(m.ttl, stats.Private))
+}
+
+// SetNotFound remembers that a player doesn't exist for the not-found TTL
+// and drops their stats
+func (m *MemoryStore) SetNotFound(platform, tag string) error {
+	if m.notFound <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	key := statsKey(platform, tag)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items.remove(key)
+	m.items.remove(profileKey(platform, tag))
+	m.items.remove(negativeKey(NegativePrivate, platform, tag))
+	m.items.set(&lruItem{
+		key:       negativeKey(NegativeNotFound, platform, tag),
+		fetchedAt: now,
+		expiresAt: now.Add(m.notFound),
+	})
+	return nil
+}
+
+// Negative returns the negative entry of a player, if any
+func (m *MemoryStore) Negative(platform, tag string) (string, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, kind := range []string{NegativeNotFound, NegativePrivate} {
+		if item, ok := m.items.get(negativeKey(kind, platform, tag)); ok {
+			return kind, item.fetchedAt, nil
+		}
+	}
+	return "", time.Time{}, nil
+}
+
+// Inspect describes all entries of a player
+func (m *MemoryStore) Inspect(platform, tag string) (*PlayerEntries, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	inspect := func(key string) *EntryInfo {
+		item, ok := m.items.get(key)
+		if !ok {
+			return nil
+		}
+		return &EntryInfo{
+			Key:        key,
+			Size:       len(item.value.([]byte)),
+			FetchedAt:  item.fetchedAt,
+			AgeSeconds: int64(now.Sub(item.fetchedAt).Seconds()),
+			TTLSeconds: ttlSeconds(item.expiresAt.Sub(now)),
+		}
+	}
+
+	entries := &PlayerEntries{
+		Complete: inspect(statsKey(platform, tag)),
+		Profile:  inspect(profileKey(platform, tag)),
+	}
+	for _, kind := range []string{NegativeNotFound, NegativePrivate} {
+		if item, ok := m.items.get(negativeKey(kind, platform, tag)); ok {
+			entries.Negative = &NegativeInfo{
+				Kind:       kind,
+				Since:      item.fetchedAt,
+				TTLSeconds: ttlSeconds(item.expiresAt.Sub(now)),
+			}
+			break
+		}
+	}
+	return entries, nil
+}
+
+// CareerID returns the career ID tag resolved to last
+func (m *MemoryStore) CareerID(tag string) (string, error) {
+	var id string
+	_, _, err := m.get(careerIDKey(tag), &id)
+	return id, err
+}
+
+// SetCareerID remembers the career ID tag resolves to for the career TTL
+func (m *MemoryStore) SetCareerID(tag, careerID string) error {
+	if m.career <= 0 {
+		return nil
+	}
+	return m.set(careerIDKey(tag), careerID, m.career)
+}
+
+// Career returns what is known about a career page
+func (m *MemoryStore) Career(careerID string) (*Career, error) {
+	var career Career
+	_, found, err := m.get(careerKey(careerID), &career)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &career, nil
+}
+
+// SetCareer stores what is known about a career page for the career TTL
+func (m *MemoryStore) SetCareer(career *Career) error {
+	if m.career <= 0 {
+		return nil
+	}
+	return m.set(careerKey(career.ID), career, m.career)
+}
+
+// Delete removes all cached entries of a player
+func (m *MemoryStore) Delete(platform, tag string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := statsKey(platform, tag)
+	m.items.remove(key)
+	m.items.remove(profileKey(platform, tag))
+	m.items.remove(negativeKey(NegativeNotFound, platform, tag))
+	m.items.remove(negativeKey(NegativePrivate, platform, tag))
+	return nil
+}
+
+// ScanKeys calls fn with the unexpired keys matching pattern
+func (m *MemoryStore) ScanKeys(pattern string, fn func(key string) error) error {
+	// fn may use the store, it runs on a snapshot without holding the lock
+	m.mu.Lock()
+	keys := m.items.keys()
+	m.mu.Unlock()
+
+	for _, key := range keys {
+		ok, err := path.Match(pattern, key)
+		if err != nil {
+			return fmt.Errorf("failed to scan keys: %w", err)
+		}
+		if !ok {
+			continue
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush clears the entire cache
+func (m *MemoryStore) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.items.flush()
+	return nil
+}
+
+// Stats returns the size of the cache
+func (m *MemoryStore) Stats() (StoreStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return StoreStats{
+		Backend:    "memory",
+		Entries:    int64(m.items.len()),
+		MaxEntries: m.items.maxEntries,
+		Evictions:  m.items.evictions,
+	}, nil
+}
+
+// TTL returns how long entries are kept
+func (m *MemoryStore) TTL() time.Duration {
+	return m.ttl
+}
+
+// Close is a no-op, it only satisfies Store
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Domekologe/ow-api/ovrstat"
+)
+
+// scanAll collects the keys ScanKeys passes
+func scanAll(t *testing.T, s Store, pattern string) []string {
+	t.Helper()
+	var keys []string
+	err := s.ScanKeys(pattern, func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestMemoryStoreLRU(t *testing.T) {
+	m := NewMemoryStore(2, time.Hour)
+
+	m.Set("pc", "A-1", &ovrstat.PlayerStats{Name: "A"})
+	m.Set("pc", "B-2", &ovrstat.PlayerStats{Name: "B"})
+	m.Get("pc", "A-1") // A is now the most recently used
+	m.Set("pc", "C-3", &ovrstat.PlayerStats{Name: "C"})
+
+	if got, _ := m.Get("pc", "B-2"); got != nil {
+		t.Errorf("B was not evicted")
+	}
+	for _, tag := range []string{"A-1", "C-3"} {
+		if got, _ := m.Get("pc", tag); got == nil {
+			t.Errorf("%s was evicted", tag)
+		}
+	}
+	if st, _ := m.Stats(); st.Entries != 2 || st.Evictions != 1 {
+		t.Errorf("Stats = %+v; want 2 entries and 1 eviction", st)
+	}
+}
+
+func TestMemoryStoreTTL(t *testing.T) {
+	m := NewMemoryStore(10, 10*time.Millisecond)
+	m.SetProfile("pc", "A-1", &ovrstat.PlayerStatsProfile{Name: "A"})
+
+	if got, _ := m.GetProfile("pc", "A-1"); got == nil {
+		t.Fatal("entry missing before TTL")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got, _ := m.GetProfile("pc", "A-1"); got != nil {
+		t.Error("entry still served after TTL")
+	}
+}
+
+func TestMemoryStoreCopies(t *testing.T) {
+	m := NewMemoryStore(10, time.Hour)
+	m.Set("pc", "A-1", &ovrstat.PlayerStats{Name: "A"})
+
+	got, _ := m.Get("pc", "A-1")
+	got.Name = "changed"
+	if again, _ := m.Get("pc", "A-1"); again.Name != "A" {
+		t.Errorf("Name = %q; a caller modified the cached entry", again.Name)
+	}
+}
+
+func TestMemoryStoreKeysAndDelete(t *testing.T) {
+	m := NewMemoryStore(10, time.Hour)
+	m.Set("pc", "A-1", &ovrstat.PlayerStats{})
+	m.SetProfile("pc", "A-1", &ovrstat.PlayerStatsProfile{})
+	m.Set("console", "B-2", &ovrstat.PlayerStats{})
+
+	keys := scanAll(t, m, "stats:pc:*")
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "stats:pc:A-1" || keys[1] != "stats:pc:A-1:profile" {
+		t.Errorf("ScanKeys = %v", keys)
+	}
+
+	m.Delete("pc", "A-1")
+	if keys := scanAll(t, m, StatsKeyPattern); len(keys) != 1 {
+		t.Errorf("ScanKeys after Delete = %v; want only console/B-2", keys)
+	}
+}
+
+func TestMemoryStoreNegative(t *testing.T) {
+	m := NewMemoryStore(10, time.Hour)
+	m.SetNegativeTTLs(time.Hour, 10*time.Millisecond)
+
+	m.Set("pc", "A-1", &ovrstat.PlayerStats{Name: "A"})
+	if err := m.SetNotFound("pc", "A-1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := m.Get("pc", "A-1"); got != nil {
+		t.Error("stats kept after SetNotFound")
+	}
+	if kind, _, _ := m.Negative("pc", "A-1"); kind != NegativeNotFound {
+		t.Errorf("Negative = %q; want %q", kind, NegativeNotFound)
+	}
+
+	// Finding the player again clears the not-found entry, a private
+	// profile is kept for the private TTL only
+	m.Set("pc", "A-1", &ovrstat.PlayerStats{Name: "A", Private: true})
+	if kind, _, _ := m.Negative("pc", "A-1"); kind != NegativePrivate {
+		t.Errorf("Negative = %q; want %q", kind, NegativePrivate)
+	}
+	keys := scanAll(t, m, NegativeKeyPattern(""))
+	if len(keys) != 1 {
+		t.Errorf("negative keys = %v; want only the private one", keys)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got, _ := m.Get("pc", "A-1"); got != nil {
+		t.Error("private stats kept beyond the private TTL")
+	}
+}
+
+func TestMemoryStoreCareer(t *testing.T) {
+	m := NewMemoryStore(10, time.Hour)
+	if err := m.SetCareerID("a-1", "A-1|x"); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := m.CareerID("a-1"); id != "" {
+		t.Errorf("CareerID = %q with career IDs disabled; want none", id)
+	}
+
+	m.SetCareerTTL(time.Hour)
+	m.SetCareerID("a-1", "A-1|x")
+	if id, _ := m.CareerID("a-1"); id != "A-1|x" {
+		t.Errorf("CareerID = %q; want %q", id, "A-1|x")
+	}
+
+	career := &Career{ID: "A-1|x"}
+	if career.SeenName("A") {
+		t.Error("first name reported as a change")
+	}
+	m.SetCareer(career)
+
+	career, _ = m.Career("A-1|x")
+	if career == nil || !career.SeenName("B") {
+		t.Fatalf("Career = %+v; want the stored career and a name change", career)
+	}
+	m.SetCareer(career)
+	career, _ = m.Career("A-1|x")
+	if len(career.NameChanges) != 1 || career.NameChanges[0].From != "A" || career.NameChanges[0].To != "B" {
+		t.Errorf("NameChanges = %+v; want A to B", career.NameChanges)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want PlayerKey
+		ok   bool
+	}{
+		{statsKey("pc", "A-1"), PlayerKey{KeyStats, "pc", "A-1"}, true},
+		{profileKey("pc", "A-1"), PlayerKey{KeyProfile, "pc", "A-1"}, true},
+		{negativeKey(NegativeNotFound, "console", "B-2"), PlayerKey{NegativeNotFound, "console", "B-2"}, true},
+		{"ratelimit:upstream:1", PlayerKey{}, false},
+		{"stats:pc", PlayerKey{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseKey(tt.key)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseKey(%q) = %+v, %v; want %+v, %v", tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+}
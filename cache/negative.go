@@ -0,0 +1,13 @@
+package cache
+
+// Kinds of negative entries. They are kept under their own keys, next to the
+// stats, so they can be listed and purged separately.
+const (
+	// NegativeNotFound marks a BattleTag Blizzard doesn't know. No stats are
+	// cached for it.
+	NegativeNotFound = "notfound"
+
+	// NegativePrivate marks a private profile. Its (empty) stats are cached
+	// as usual, for the private TTL.
+	NegativePrivate = "private"
+)
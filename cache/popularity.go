@@ -0,0 +1,270 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Keys of the access records, sorted sets of "platform:tag" members scored by
+// their number of lookups and the Unix time of the last one. The {access} hash
+// tag keeps them in one slot of a Redis Cluster, so they are updated together.
+const (
+	accessCountKey = "access:{access}:count"
+	accessLastKey  = "access:{access}:last"
+)
+
+// maxPendingAccesses caps the players whose lookups are buffered between two
+// flushes
+const maxPendingAccesses = 10000
+
+// Access is what the API recorded about the lookups of a player
+type Access struct {
+	// Count is the number of lookups, halved by every Decay
+	Count float64
+	// Last is when the player was last looked up, zero if they aren't
+	// tracked yet
+	Last time.Time
+}
+
+// Idle reports whether the player was tracked but not looked up for idle
+func (a Access) Idle(idle time.Duration, now time.Time) bool {
+	return !a.Last.IsZero() && now.Sub(a.Last) > idle
+}
+
+// Popularity records how often and how recently the API looks up players.
+// Lookups are buffered in process and written by Flush.
+type Popularity struct {
+	cache *RedisCache
+
+	mu      sync.Mutex
+	pending map[string]int64
+}
+
+// Popularity returns the access records in this Redis
+func (c *RedisCache) Popularity() *Popularity {
+	return &Popularity{cache: c, pending: make(map[string]int64)}
+}
+
+// Record counts a lookup of a player. Lookups of new players are dropped
+// while too many are waiting for a flush.
+func (p *Popularity) Record(platform, tag string) {
+	member := platform + ":" + tag
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.pending[member]; ok || len(p.pending) < maxPendingAccesses {
+		p.pending[member]++
+	}
+}
+
+// Flush writes the buffered lookups to Redis. They are dropped if that
+// fails.
+func (p *Popularity) Flush() error {
+	p.mu.Lock()
+	pending := p.pending
+	p.pending = make(map[string]int64)
+	p.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ctx := p.cache.ctx
+	now := float64(time.Now().Unix())
+	_, err := p.cache.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for member, n := range pending {
+			pipe.ZIncrBy(ctx, p.cache.key(accessCountKey), float64(n), member)
+			pipe.ZAdd(ctx, p.cache.key(accessLastKey), redis.Z{Score: now, Member: member})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record player lookups: %w", err)
+	}
+	return nil
+}
+
+// Run flushes the buffered lookups every interval until ctx is done
+func (p *Popularity) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.Flush(); err != nil {
+				log.Printf("Failed to flush player lookups: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Accesses returns the access records of players, in the same order
+func (p *Popularity) Accesses(players []PlayerKey) ([]Access, error) {
+	if len(players) == 0 {
+		return nil, nil
+	}
+	members := make([]string, len(players))
+	for i, pk := range players {
+		members[i] = pk.Platform + ":" + pk.Tag
+	}
+
+	ctx := p.cache.ctx
+	var counts, lasts []*redis.FloatSliceCmd
+	_, err := p.cache.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for batch := range slices.Chunk(members, scanCount) {
+			counts = append(counts, pipe.ZMScore(ctx, p.cache.key(accessCountKey), batch...))
+			lasts = append(lasts, pipe.ZMScore(ctx, p.cache.key(accessLastKey), batch...))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get player lookups: %w", err)
+	}
+
+	// ZMSCORE answers nil for missing members, which go-redis reads as 0
+	accesses := make([]Access, 0, len(players))
+	for b := range counts {
+		for i, count := range counts[b].Val() {
+			a := Access{Count: count}
+			if last := lasts[b].Val()[i]; last > 0 {
+				a.Last = time.Unix(int64(last), 0)
+			}
+			accesses = append(accesses, a)
+		}
+	}
+	return accesses, nil
+}
+
+// Seed starts tracking players as if they were looked up now. Players that
+// are tracked already are left alone.
+func (p *Popularity) Seed(players []PlayerKey) error {
+	if len(players) == 0 {
+		return nil
+	}
+	ctx := p.cache.ctx
+	now := float64(time.Now().Unix())
+	_, err := p.cache.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for batch := range slices.Chunk(players, scanCount) {
+			members := make([]redis.Z, len(batch))
+			for i, pk := range batch {
+				members[i] = redis.Z{Score: now, Member: pk.Platform + ":" + pk.Tag}
+			}
+			pipe.ZAddNX(ctx, p.cache.key(accessLastKey), members...)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to track players: %w", err)
+	}
+	return nil
+}
+
+// Prune forgets the players not looked up for idle, unless keep says
+// otherwise, and returns how many there were. Idle players that are still
+// cached should be kept, or they are tracked anew by the next Seed.
+func (p *Popularity) Prune(idle time.Duration, keep func(platform, tag string) bool) (int64, error) {
+	ctx := p.cache.ctx
+	cutoff := strconv.FormatInt(time.Now().Add(-idle).Unix(), 10)
+	idlers, err := p.cache.client.ZRangeByScore(ctx, p.cache.key(accessLastKey), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + cutoff,
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune player lookups: %w", err)
+	}
+
+	var forget []interface{}
+	for _, member := range idlers {
+		platform, tag, _ := strings.Cut(member, ":")
+		if !keep(platform, tag) {
+			forget = append(forget, member)
+		}
+	}
+	if len(forget) == 0 {
+		return 0, nil
+	}
+	_, err = p.cache.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for batch := range slices.Chunk(forget, scanCount) {
+			pipe.ZRem(ctx, p.cache.key(accessCountKey), batch...)
+			pipe.ZRem(ctx, p.cache.key(accessLastKey), batch...)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune player lookups: %w", err)
+	}
+	return int64(len(forget)), nil
+}
+
+// Decay halves the lookup counts of all players, so popularity follows
+// recent lookups
+func (p *Popularity) Decay() error {
+	key := p.cache.key(accessCountKey)
+	err := p.cache.client.ZUnionStore(p.cache.ctx, key, &redis.ZStore{
+		Keys:    []string{key},
+		Weights: []float64{0.5},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to decay player lookups: %w", err)
+	}
+	return nil
+}
+
+// Tracked returns the number of players with access records
+func (p *Popularity) Tracked() (int64, error) {
+	n, err := p.cache.client.ZCard(p.cache.ctx, p.cache.key(accessLastKey)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count player lookups: %w", err)
+	}
+	return n, nil
+}
+
+// Candidate is a cached player considered by a scheduled refresh
+type Candidate struct {
+	Access Access
+	Entry  EntryAge
+}
+
+// Schedule returns the indexes of the candidates to refresh, in the order
+// they should be. Players not looked up for idleAfter are left out, 0 keeps
+// all of them. Entries expiring within refreshAhead go first, soonest first,
+// the others by how popular and how old they are.
+func Schedule(candidates []Candidate, idleAfter, refreshAhead time.Duration, now time.Time) []int {
+	expiring := func(c Candidate) bool {
+		return c.Entry.ExpiresIn > 0 && c.Entry.ExpiresIn < refreshAhead
+	}
+	score := func(c Candidate) float64 {
+		return (c.Access.Count + 1) * c.Entry.Age.Seconds()
+	}
+
+	var order []int
+	for i, c := range candidates {
+		if idleAfter > 0 && c.Access.Idle(idleAfter, now) {
+			continue
+		}
+		order = append(order, i)
+	}
+	slices.SortStableFunc(order, func(i, j int) int {
+		a, b := candidates[i], candidates[j]
+		switch {
+		case expiring(a) && expiring(b):
+			return cmp.Compare(a.Entry.ExpiresIn, b.Entry.ExpiresIn)
+		case expiring(a):
+			return -1
+		case expiring(b):
+			return 1
+		}
+		return cmp.Compare(score(b), score(a))
+	})
+	return order
+}
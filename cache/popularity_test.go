@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	now := time.Now()
+	hour := time.Hour
+	candidates := []Candidate{
+		// Never looked up since lookups are recorded
+		{Access: Access{}, Entry: EntryAge{Age: hour, ExpiresIn: 20 * hour}},
+		// Idle
+		{Access: Access{Count: 50, Last: now.Add(-200 * hour)}, Entry: EntryAge{Age: 23 * hour, ExpiresIn: 30 * time.Minute}},
+		// Popular
+		{Access: Access{Count: 10, Last: now}, Entry: EntryAge{Age: hour, ExpiresIn: 20 * hour}},
+		// Unpopular, as old as the untracked one
+		{Access: Access{Last: now.Add(-100 * hour)}, Entry: EntryAge{Age: hour, ExpiresIn: 20 * hour}},
+		// Expiring, the second one sooner
+		{Access: Access{Last: now}, Entry: EntryAge{Age: 22 * hour, ExpiresIn: 90 * time.Minute}},
+		{Access: Access{Last: now}, Entry: EntryAge{Age: 23 * hour, ExpiresIn: 10 * time.Minute}},
+	}
+
+	if got, want := Schedule(candidates, 168*hour, 2*hour, now), []int{5, 4, 2, 0, 3}; !slices.Equal(got, want) {
+		t.Errorf("Schedule = %v; want %v", got, want)
+	}
+	// Without an idle period every player is refreshed, without a window
+	// the expiring ones are ordered like all others
+	if got, want := Schedule(candidates, 0, 0, now), []int{1, 5, 4, 2, 0, 3}; !slices.Equal(got, want) {
+		t.Errorf("Schedule without idle period and window = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// RateBudget is an upstream request budget shared through Redis by every
+// process connected to it. It counts requests in one second windows and
+// satisfies ovrstat.SharedBudget.
+type RateBudget struct {
+	cache     *RedisCache
+	perSecond int64
+}
+
+// RateBudget returns a budget of rate requests per second, rounded up, shared
+// by all processes using this Redis
+func (c *RedisCache) RateBudget(rate float64) *RateBudget {
+	return &RateBudget{cache: c, perSecond: int64(math.Ceil(rate))}
+}
+
+// Take reports whether one more request fits into the current window
+func (b *RateBudget) Take(ctx context.Context) (bool, error) {
+	key := b.cache.key(fmt.Sprintf("ratelimit:upstream:%d", time.Now().Unix()))
+
+	pipe := b.cache.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, 2*time.Second)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("failed to take from rate budget: %w", err)
+	}
+	return incr.Val() <= b.perSecond, nil
+}
@@ -5,140 +5,484 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Domekologe/ow-api/ovrstat"
 	"github.com/redis/go-redis/v9"
 )
 
+// scanCount is how many keys SCAN looks at per call, and how many keys Flush
+// deletes at once
+const scanCount = 500
+
 // RedisCache wraps the Redis client for caching player stats
 type RedisCache struct {
-	client *redis.Client
+	client redis.UniversalClient
 	ttl    time.Duration
 	ctx    context.Context
-}
 
-// NewRedisCache creates a new Redis cache client
-func NewRedisCache(host string, port int, password string, db int, ttl time.Duration) (*RedisCache, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", host, port),
-		Password: password,
-		DB:       db,
-	})
+	// origin identifies this process in invalidation messages
+	origin string
+	// source is stored with every entry, see SetSource
+	source string
+	// prefix namespaces all keys, see SetKeyPrefix
+	prefix string
+
+	entryTTLs
+}
 
-	ctx := context.Background()
+// NewRedisCache creates a new Redis cache client and checks that Redis is
+// reachable
+func NewRedisCache(opts RedisOptions, ttl time.Duration) (*RedisCache, error) {
+	c, err := DialRedis(opts, ttl)
+	if err != nil {
+		return nil, err
+	}
 
 	// Test connection
-	if err := client.Ping(ctx).Err(); err != nil {
+	if err := c.Ping(); err != nil {
+		c.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	log.Printf("Connected to Redis at %s:%d", host, port)
+	log.Printf("Connected to Redis at %s", opts)
+	return c, nil
+}
+
+// DialRedis creates a new Redis cache client without checking the
+// connection. go-redis connects on first use and reconnects on its own, so
+// Redis doesn't need to be up yet. It only fails on invalid options.
+func DialRedis(opts RedisOptions, ttl time.Duration) (*RedisCache, error) {
+	client, err := opts.newClient()
+	if err != nil {
+		return nil, err
+	}
 
 	return &RedisCache{
 		client: client,
 		ttl:    ttl,
-		ctx:    ctx,
+		ctx:    context.Background(),
+		origin: newOrigin(),
+		source: "api",
+		prefix: DefaultKeyPrefix,
 	}, nil
 }
 
-// makeKey generates a cache key for a player
-func makeKey(platform, tag string) string {
-	return fmt.Sprintf("ow:stats:%s:%s", platform, tag)
+// SetKeyPrefix namespaces all keys, so several deployments can share one
+// Redis database. It must be called before the cache is used, an empty
+// prefix keeps DefaultKeyPrefix.
+func (c *RedisCache) SetKeyPrefix(prefix string) {
+	if prefix != "" {
+		c.prefix = prefix
+	}
 }
 
-// Get retrieves cached player stats
-func (c *RedisCache) Get(platform, tag string) (*ovrstat.PlayerStats, error) {
-	key := makeKey(platform, tag)
-	data, err := c.client.Get(c.ctx, key).Bytes()
+// key returns the Redis key of a key relative to the prefix
+func (c *RedisCache) key(rel string) string {
+	return c.prefix + ":" + rel
+}
+
+// SetSource names the process writing entries, e.g. "api" or "scraper". It
+// is stored with every entry to tell where cached stats came from.
+func (c *RedisCache) SetSource(source string) {
+	c.source = source
+}
+
+// setEntry stores v under key together with the current time
+func (c *RedisCache) setEntry(key string, v interface{}, ttl time.Duration) error {
+	data, err := encodeEntry(v, c.source)
+	if err != nil {
+		return err
+	}
+
+	if err := c.client.Set(c.ctx, c.key(key), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set cache: %w", err)
+	}
+	c.publishInvalidation(key)
+	return nil
+}
+
+// getEntry decodes the value stored under key into v and returns when it was
+// fetched. found is false on a cache miss, which includes entries of an
+// incompatible schema.
+func (c *RedisCache) getEntry(key string, v interface{}) (fetchedAt time.Time, found bool, err error) {
+	data, err := c.client.Get(c.ctx, c.key(key)).Bytes()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, nil // Cache miss
+			return time.Time{}, false, nil // Cache miss
 		}
-		return nil, fmt.Errorf("failed to get from cache: %w", err)
+		return time.Time{}, false, fmt.Errorf("failed to get from cache: %w", err)
 	}
 
-	var stats ovrstat.PlayerStats
-	if err := json.Unmarshal(data, &stats); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal cached data: %w", err)
+	e, bare, err := decodeEntry(data)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if bare {
+		// Written before entries had an envelope. Their age can only be
+		// estimated from the remaining TTL.
+		e.FetchedAt = c.estimateFetchedAt(key)
+	}
+	if !e.compatible() {
+		return time.Time{}, false, nil
 	}
 
-	return &stats, nil
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to unmarshal cached data: %w", err)
+	}
+	return e.FetchedAt, true, nil
 }
 
-// Set stores player stats in cache
+// estimateFetchedAt derives when a bare entry was written from how much of
+// its TTL is left. Entries without a TTL are treated as a full TTL old.
+func (c *RedisCache) estimateFetchedAt(key string) time.Time {
+	left, err := c.client.PTTL(c.ctx, c.key(key)).Result()
+	if err != nil || left < 0 {
+		left = 0
+	}
+	return time.Now().Add(left - c.ttl)
+}
+
+// Get retrieves cached player stats
+func (c *RedisCache) Get(platform, tag string) (*ovrstat.PlayerStats, error) {
+	stats, _, err := c.GetEntry(platform, tag)
+	return stats, err
+}
+
+// GetEntry retrieves cached player stats and when they were fetched
+func (c *RedisCache) GetEntry(platform, tag string) (*ovrstat.PlayerStats, time.Time, error) {
+	var stats ovrstat.PlayerStats
+	fetchedAt, found, err := c.getEntry(statsKey(platform, tag), &stats)
+	if err != nil || !found {
+		return nil, time.Time{}, err
+	}
+	return &stats, fetchedAt, nil
+}
+
+// Set stores player stats in cache. It also records whether the profile is
+// private and clears a not-found entry.
 func (c *RedisCache) Set(platform, tag string, stats *ovrstat.PlayerStats) error {
-	key := makeKey(platform, tag)
-	data, err := json.Marshal(stats)
+	key := statsKey(platform, tag)
+	data, err := encodeEntry(stats, c.source)
 	if err != nil {
-		return fmt.Errorf("failed to marshal stats: %w", err)
+		return err
 	}
 
-	if err := c.client.Set(c.ctx, key, data, c.ttl).Err(); err != nil {
+	ttl := c.statsTTL(c.ttl, stats.Private)
+	privateKey := c.key(negativeKey(NegativePrivate, platform, tag))
+	_, err = c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(c.ctx, c.key(key), data, ttl)
+		pipe.Del(c.ctx, c.key(negativeKey(NegativeNotFound, platform, tag)))
+		if stats.Private {
+			pipe.Set(c.ctx, privateKey, time.Now().UTC().Format(time.RFC3339), ttl)
+		} else {
+			pipe.Del(c.ctx, privateKey)
+		}
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to set cache: %w", err)
 	}
-
+	c.publishInvalidation(key)
 	return nil
 }
 
 // GetProfile retrieves cached player profile stats
 func (c *RedisCache) GetProfile(platform, tag string) (*ovrstat.PlayerStatsProfile, error) {
-	key := makeKey(platform, tag) + ":profile"
-	data, err := c.client.Get(c.ctx, key).Bytes()
-	if err != nil {
-		if err == redis.Nil {
-			return nil, nil // Cache miss
-		}
-		return nil, fmt.Errorf("failed to get profile from cache: %w", err)
-	}
+	stats, _, err := c.GetProfileEntry(platform, tag)
+	return stats, err
+}
 
+// GetProfileEntry retrieves cached player profile stats and when they were
+// fetched
+func (c *RedisCache) GetProfileEntry(platform, tag string) (*ovrstat.PlayerStatsProfile, time.Time, error) {
 	var stats ovrstat.PlayerStatsProfile
-	if err := json.Unmarshal(data, &stats); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal cached profile data: %w", err)
+	fetchedAt, found, err := c.getEntry(profileKey(platform, tag), &stats)
+	if err != nil || !found {
+		return nil, time.Time{}, err
 	}
-
-	return &stats, nil
+	return &stats, fetchedAt, nil
 }
 
 // SetProfile stores player profile stats in cache
 func (c *RedisCache) SetProfile(platform, tag string, stats *ovrstat.PlayerStatsProfile) error {
-	key := makeKey(platform, tag) + ":profile"
-	data, err := json.Marshal(stats)
+	return c.setEntry(profileKey(platform, tag), stats, c.statsTTL(c.ttl, stats.Private))
+}
+
+// SetNotFound remembers that a player doesn't exist for the not-found TTL
+// and drops their stats
+func (c *RedisCache) SetNotFound(platform, tag string) error {
+	if c.notFound <= 0 {
+		return nil
+	}
+
+	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(c.ctx, c.key(statsKey(platform, tag)))
+		pipe.Del(c.ctx, c.key(profileKey(platform, tag)))
+		pipe.Del(c.ctx, c.key(negativeKey(NegativePrivate, platform, tag)))
+		pipe.Set(c.ctx, c.key(negativeKey(NegativeNotFound, platform, tag)),
+			time.Now().UTC().Format(time.RFC3339), c.notFound)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to marshal profile stats: %w", err)
+		return fmt.Errorf("failed to set cache: %w", err)
+	}
+	c.publishInvalidation(statsKey(platform, tag), profileKey(platform, tag))
+	return nil
+}
+
+// Negative returns the negative entry of a player, if any
+func (c *RedisCache) Negative(platform, tag string) (string, time.Time, error) {
+	kinds := []string{NegativeNotFound, NegativePrivate}
+	cmds := make([]*redis.StringCmd, len(kinds))
+	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for i, kind := range kinds {
+			cmds[i] = pipe.Get(c.ctx, c.key(negativeKey(kind, platform, tag)))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return "", time.Time{}, fmt.Errorf("failed to get from cache: %w", err)
+	}
+
+	for i, cmd := range cmds {
+		v, err := cmd.Result()
+		if err != nil {
+			continue
+		}
+		since, _ := time.Parse(time.RFC3339, v)
+		return kinds[i], since, nil
+	}
+	return "", time.Time{}, nil
+}
+
+// Inspect describes all entries of a player
+func (c *RedisCache) Inspect(platform, tag string) (*PlayerEntries, error) {
+	keys := []string{
+		statsKey(platform, tag),
+		profileKey(platform, tag),
+		negativeKey(NegativeNotFound, platform, tag),
+		negativeKey(NegativePrivate, platform, tag),
+	}
+	gets := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			gets[i] = pipe.Get(c.ctx, c.key(key))
+			ttls[i] = pipe.PTTL(c.ctx, c.key(key))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get from cache: %w", err)
+	}
+
+	// inspect describes the stats entry of keys[i], nil if there is none
+	inspect := func(i int) (*EntryInfo, error) {
+		data, err := gets[i].Bytes()
+		if err != nil {
+			return nil, nil
+		}
+		info, err := inspectEntry(keys[i], data, ttls[i].Val())
+		if err == nil && info.FetchedAt.IsZero() {
+			info.FetchedAt = c.estimateFetchedAt(keys[i])
+			info.AgeSeconds = int64(time.Since(info.FetchedAt).Seconds())
+		}
+		return info, err
 	}
 
-	if err := c.client.Set(c.ctx, key, data, c.ttl).Err(); err != nil {
-		return fmt.Errorf("failed to set profile cache: %w", err)
+	var entries PlayerEntries
+	if entries.Complete, err = inspect(0); err != nil {
+		return nil, err
 	}
+	if entries.Profile, err = inspect(1); err != nil {
+		return nil, err
+	}
+	for i, kind := range []string{NegativeNotFound, NegativePrivate} {
+		data, err := gets[2+i].Bytes()
+		if err != nil {
+			continue
+		}
+		since, _ := time.Parse(time.RFC3339, string(data))
+		entries.Negative = &NegativeInfo{Kind: kind, Since: since, TTLSeconds: ttlSeconds(ttls[2+i].Val())}
+		break
+	}
+	return &entries, nil
+}
 
+// CareerID returns the career ID tag resolved to last
+func (c *RedisCache) CareerID(tag string) (string, error) {
+	id, err := c.client.Get(c.ctx, c.key(careerIDKey(tag))).Result()
+	if err != nil && err != redis.Nil {
+		return "", fmt.Errorf("failed to get from cache: %w", err)
+	}
+	return id, nil
+}
+
+// SetCareerID remembers the career ID tag resolves to for the career TTL
+func (c *RedisCache) SetCareerID(tag, careerID string) error {
+	if c.career <= 0 {
+		return nil
+	}
+	if err := c.client.Set(c.ctx, c.key(careerIDKey(tag)), careerID, c.career).Err(); err != nil {
+		return fmt.Errorf("failed to set cache: %w", err)
+	}
 	return nil
 }
 
-// GetKeys returns all cached player keys matching the pattern
-func (c *RedisCache) GetKeys(pattern string) ([]string, error) {
-	keys, err := c.client.Keys(c.ctx, pattern).Result()
+// Career returns what is known about a career page
+func (c *RedisCache) Career(careerID string) (*Career, error) {
+	data, err := c.client.Get(c.ctx, c.key(careerKey(careerID))).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get from cache: %w", err)
+	}
+
+	var career Career
+	if err := json.Unmarshal(data, &career); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cached data: %w", err)
+	}
+	return &career, nil
+}
+
+// SetCareer stores what is known about a career page for the career TTL
+func (c *RedisCache) SetCareer(career *Career) error {
+	if c.career <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(career)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get keys: %w", err)
+		return fmt.Errorf("failed to marshal career: %w", err)
 	}
-	return keys, nil
+	if err := c.client.Set(c.ctx, c.key(careerKey(career.ID)), data, c.career).Err(); err != nil {
+		return fmt.Errorf("failed to set cache: %w", err)
+	}
+	return nil
 }
 
-// Delete removes a cached entry
+// scan calls fn with the Redis keys matching pattern, without blocking Redis
+// like KEYS does. In a cluster every master is scanned.
+func (c *RedisCache) scan(pattern string, fn func(key string) error) error {
+	cluster, ok := c.client.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(c.ctx, c.client, pattern, fn)
+	}
+
+	// Masters are scanned concurrently, fn is called by one at a time
+	var mu sync.Mutex
+	return cluster.ForEachMaster(c.ctx, func(ctx context.Context, node *redis.Client) error {
+		return scanNode(ctx, node, pattern, func(key string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return fn(key)
+		})
+	})
+}
+
+// scanNode calls fn with the keys of one Redis node matching pattern
+func scanNode(ctx context.Context, node redis.Cmdable, pattern string, fn func(key string) error) error {
+	iter := node.Scan(ctx, 0, pattern, scanCount).Iterator()
+	for iter.Next(ctx) {
+		if err := fn(iter.Val()); err != nil {
+			return err
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan keys: %w", err)
+	}
+	return nil
+}
+
+// ScanKeys calls fn with every key matching pattern. Both are relative to
+// the key prefix. Keys changed during the scan may be passed twice or not at
+// all.
+func (c *RedisCache) ScanKeys(pattern string, fn func(key string) error) error {
+	return c.scan(c.key(pattern), func(key string) error {
+		return fn(strings.TrimPrefix(key, c.prefix+":"))
+	})
+}
+
+// Delete removes all cached entries of a player
 func (c *RedisCache) Delete(platform, tag string) error {
-	key := makeKey(platform, tag)
-	if err := c.client.Del(c.ctx, key).Err(); err != nil {
+	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(c.ctx, c.key(statsKey(platform, tag)))
+		pipe.Del(c.ctx, c.key(profileKey(platform, tag)))
+		pipe.Del(c.ctx, c.key(negativeKey(NegativeNotFound, platform, tag)))
+		pipe.Del(c.ctx, c.key(negativeKey(NegativePrivate, platform, tag)))
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to delete from cache: %w", err)
 	}
+	c.publishInvalidation(statsKey(platform, tag), profileKey(platform, tag))
 	return nil
 }
 
-// FlushAll clears the entire cache
-func (c *RedisCache) FlushAll() error {
-	if err := c.client.FlushDB(c.ctx).Err(); err != nil {
-		return fmt.Errorf("failed to flush cache: %w", err)
+// Flush removes every entry under the prefix. Other data, in the database or
+// under the prefix, is left alone.
+func (c *RedisCache) Flush() error {
+	batch := make([]string, 0, scanCount)
+	unlink := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		// One UNLINK per key, in a cluster the keys of a batch live in
+		// different slots
+		_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range batch {
+				pipe.Unlink(c.ctx, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to flush cache: %w", err)
+		}
+		batch = batch[:0]
+		return nil
 	}
-	return nil
+
+	var err error
+	for _, pattern := range entryKeyPatterns {
+		err = c.scan(c.key(pattern), func(key string) error {
+			batch = append(batch, key)
+			if len(batch) < scanCount {
+				return nil
+			}
+			return unlink()
+		})
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = unlink()
+	}
+	c.publishInvalidation(invalidateAll)
+	return err
+}
+
+// Stats returns the number of entries under the prefix
+func (c *RedisCache) Stats() (StoreStats, error) {
+	var n int64
+	for _, pattern := range entryKeyPatterns {
+		err := c.scan(c.key(pattern), func(string) error {
+			n++
+			return nil
+		})
+		if err != nil {
+			return StoreStats{}, fmt.Errorf("failed to get cache stats: %w", err)
+		}
+	}
+	return StoreStats{Backend: "redis", Entries: n, KeyPrefix: c.prefix}, nil
+}
+
+// TTL returns how long entries are kept
+func (c *RedisCache) TTL() time.Duration {
+	return c.ttl
 }
 
 // Close closes the Redis connection
@@ -150,3 +494,72 @@ func (c *RedisCache) Close() error {
 func (c *RedisCache) Ping() error {
 	return c.client.Ping(c.ctx).Err()
 }
+
+// extendScript sets the TTL of the key KEYS[1] to ARGV[1] milliseconds if it
+// expires sooner
+var extendScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl >= 0 and ttl < tonumber(ARGV[1]) then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// Extend keeps the stats of a player for at least ttl, e.g. when they can't
+// be refreshed. Entries that are kept longer are left alone.
+func (c *RedisCache) Extend(platform, tag string, ttl time.Duration) error {
+	// One key at a time, in a cluster they may live in different slots
+	for _, key := range []string{statsKey(platform, tag), profileKey(platform, tag)} {
+		if err := extendScript.Run(c.ctx, c.client, []string{c.key(key)}, ttl.Milliseconds()).Err(); err != nil {
+			return fmt.Errorf("failed to extend cache TTL: %w", err)
+		}
+	}
+	return nil
+}
+
+// EntryAge tells how old the entries of a player are and how long they are
+// kept
+type EntryAge struct {
+	// Age is estimated from the remaining TTL and the TTL of the kind of
+	// entry. Entries kept longer by Extend count as new, players without
+	// entries as a full TTL old.
+	Age time.Duration
+	// ExpiresIn is how long the complete stats are kept, 0 without any
+	ExpiresIn time.Duration
+}
+
+// EntryAges returns how old the entries of each player are, without reading
+// them
+func (c *RedisCache) EntryAges(players []PlayerKey) ([]EntryAge, error) {
+	type ttls struct{ stats, private, notFound *redis.DurationCmd }
+	cmds := make([]ttls, len(players))
+	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for i, pk := range players {
+			cmds[i] = ttls{
+				stats:    pipe.PTTL(c.ctx, c.key(statsKey(pk.Platform, pk.Tag))),
+				private:  pipe.PTTL(c.ctx, c.key(negativeKey(NegativePrivate, pk.Platform, pk.Tag))),
+				notFound: pipe.PTTL(c.ctx, c.key(negativeKey(NegativeNotFound, pk.Platform, pk.Tag))),
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache TTLs: %w", err)
+	}
+
+	// PTTL is negative for keys that don't exist or don't expire
+	ages := make([]EntryAge, len(players))
+	for i, cmd := range cmds {
+		stats, notFound := cmd.stats.Val(), cmd.notFound.Val()
+		switch {
+		case stats > 0:
+			kept := c.statsTTL(c.ttl, cmd.private.Val() > 0)
+			ages[i] = EntryAge{Age: max(kept-stats, 0), ExpiresIn: stats}
+		case notFound > 0:
+			ages[i] = EntryAge{Age: max(c.notFound-notFound, 0)}
+		default:
+			ages[i] = EntryAge{Age: c.ttl}
+		}
+	}
+	return ages, nil
+}
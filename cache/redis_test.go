@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Domekologe/ow-api/ovrstat"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestRedis returns a RedisCache on an in-process Redis
+func newTestRedis(t *testing.T) *RedisCache {
+	t.Helper()
+	s := miniredis.RunT(t)
+	port, _ := strconv.Atoi(s.Port())
+	rc, err := DialRedis(RedisOptions{Host: s.Host(), Port: port}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rc.Close() })
+	return rc
+}
+
+func TestRedisFlushEntriesOnly(t *testing.T) {
+	rc := newTestRedis(t)
+	rc.SetCareerTTL(time.Hour)
+
+	if err := rc.Set("pc", "foo-1234", &ovrstat.PlayerStats{Name: "Foo"}); err != nil {
+		t.Fatal(err)
+	}
+	rc.SetCareerID("foo-1234", "Foo-1234|abc")
+
+	q := rc.RefreshQueue(0, DropNewest)
+	q.Push(RefreshJob{Platform: "pc", Tag: "bar-5678", Priority: PriorityPeriodic})
+	runs := rc.ScraperRuns()
+	run, err := runs.Create(TriggerSchedule, RunTarget{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pop := rc.Popularity()
+	pop.Record("pc", "foo-1234")
+	if err := pop.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if st, _ := rc.Stats(); st.Entries != 2 {
+		t.Errorf("Stats counted %d entries; want 2", st.Entries)
+	}
+	if err := rc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats, _ := rc.Get("pc", "foo-1234"); stats != nil {
+		t.Error("stats survived the flush")
+	}
+	if id, _ := rc.CareerID("foo-1234"); id != "" {
+		t.Error("career ID survived the flush")
+	}
+	if st, _ := q.Stats(); st.Periodic != 1 {
+		t.Errorf("queue holds %d jobs after the flush; want 1", st.Periodic)
+	}
+	if r, _ := runs.Get(run.ID); r == nil {
+		t.Error("scraper run was flushed")
+	}
+	if n, _ := pop.Tracked(); n != 1 {
+		t.Errorf("%d players tracked after the flush; want 1", n)
+	}
+}
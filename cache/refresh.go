@@ -0,0 +1,288 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RefreshPriority orders refresh jobs, lower values are refreshed first
+type RefreshPriority int
+
+// Priorities of refresh jobs
+const (
+	// PriorityOnDemand is a refresh a request asked for, e.g. after a live
+	// lookup timed out
+	PriorityOnDemand RefreshPriority = iota
+	// PriorityPeriodic is a refresh of the scraper's regular pass
+	PriorityPeriodic
+
+	numPriorities
+)
+
+// String returns the name of p as used in QueueStats
+func (p RefreshPriority) String() string {
+	if p == PriorityOnDemand {
+		return "ondemand"
+	}
+	return "periodic"
+}
+
+// DropPolicy decides which job is dropped when a job is pushed to a full
+// queue. On-demand jobs always make room by dropping a periodic one if there
+// is any, the policy picks which. Otherwise it decides between the pushed job
+// and the queued ones of the same priority.
+type DropPolicy string
+
+const (
+	// DropNewest drops the newest job, the pushed one if it has the lowest
+	// priority
+	DropNewest DropPolicy = "newest"
+	// DropOldest drops the oldest job of the lowest priority
+	DropOldest DropPolicy = "oldest"
+)
+
+// ErrQueueFull is returned by Push when the pushed job was dropped
+var ErrQueueFull = errors.New("refresh queue is full")
+
+// RefreshJob asks for the stats of a player to be refreshed. Tag is the
+// canonical key tag, see ovrstat.PlayerID.Key.
+type RefreshJob struct {
+	Platform string
+	Tag      string
+	Priority RefreshPriority
+	// Run is the ID of the ScraperRun the job belongs to, if any
+	Run string
+}
+
+// member identifies the player of a job in a queue
+func (j RefreshJob) member() string {
+	return j.Platform + ":" + j.Tag
+}
+
+// jobFromMember is the inverse of RefreshJob.member
+func jobFromMember(member string, priority RefreshPriority, run string) RefreshJob {
+	platform, tag, _ := strings.Cut(member, ":")
+	return RefreshJob{Platform: platform, Tag: tag, Priority: priority, Run: run}
+}
+
+// RefreshQueue holds the players waiting to be refreshed. A player is queued
+// at most once, pushing an on-demand job for a player queued as periodic
+// moves it ahead and keeps its run. Jobs are popped by priority, oldest
+// first.
+type RefreshQueue interface {
+	// Push queues a job. queued is false if the player already was, the
+	// error is ErrQueueFull if the job was dropped. dropped is the queued
+	// job that was dropped to make room for it, if any.
+	Push(job RefreshJob) (queued bool, dropped *RefreshJob, err error)
+
+	// Pop waits for the next job of priority upTo or a more urgent one
+	// until ctx is done
+	Pop(ctx context.Context, upTo RefreshPriority) (RefreshJob, error)
+
+	// TryPop returns the next job, ok is false if the queue is empty
+	TryPop() (job RefreshJob, ok bool, err error)
+
+	Stats() (QueueStats, error)
+}
+
+// QueueStats describes the state of a RefreshQueue
+type QueueStats struct {
+	Backend    string     `json:"backend"`
+	OnDemand   int64      `json:"ondemand"`
+	Periodic   int64      `json:"periodic"`
+	MaxLength  int        `json:"max_length,omitempty"`
+	DropPolicy DropPolicy `json:"drop_policy"`
+	// Dropped counts the jobs this process dropped
+	Dropped int64 `json:"dropped"`
+}
+
+// Compile time checks that all backends satisfy RefreshQueue
+var (
+	_ RefreshQueue = (*MemoryQueue)(nil)
+	_ RefreshQueue = (*RedisQueue)(nil)
+)
+
+// MemoryQueue is an in-process RefreshQueue
+type MemoryQueue struct {
+	mu     sync.Mutex
+	jobs   [numPriorities][]string
+	queued map[string]RefreshJob
+
+	maxLen  int
+	policy  DropPolicy
+	dropped int64
+
+	// wake is closed and replaced when jobs are pushed, which wakes every
+	// waiting Pop
+	wake chan struct{}
+}
+
+// NewMemoryQueue creates a queue of at most maxLen jobs, unbounded if maxLen
+// is 0
+func NewMemoryQueue(maxLen int, policy DropPolicy) *MemoryQueue {
+	return &MemoryQueue{
+		queued: make(map[string]RefreshJob),
+		maxLen: maxLen,
+		policy: policy,
+		wake:   make(chan struct{}),
+	}
+}
+
+// signal wakes the waiting Pops. q.mu must be held.
+func (q *MemoryQueue) signal() {
+	close(q.wake)
+	q.wake = make(chan struct{})
+}
+
+// remove drops a queued job
+func (q *MemoryQueue) remove(job RefreshJob) {
+	member := job.member()
+	if i := slices.Index(q.jobs[job.Priority], member); i != -1 {
+		q.jobs[job.Priority] = slices.Delete(q.jobs[job.Priority], i, i+1)
+	}
+	delete(q.queued, member)
+}
+
+// Push queues a job, see RefreshQueue
+func (q *MemoryQueue) Push(job RefreshJob) (bool, *RefreshJob, error) {
+	member := job.member()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var dropped *RefreshJob
+	if cur, ok := q.queued[member]; ok {
+		if cur.Priority <= job.Priority {
+			return false, nil, nil
+		}
+		q.remove(cur)
+		job.Run = cur.Run
+	} else if q.maxLen > 0 && len(q.queued) >= q.maxLen {
+		victim, ok := q.victim(job.Priority)
+		q.dropped++
+		if !ok {
+			return false, nil, ErrQueueFull
+		}
+		v := q.queued[victim]
+		q.remove(v)
+		dropped = &v
+	}
+
+	q.jobs[job.Priority] = append(q.jobs[job.Priority], member)
+	q.queued[member] = job
+	q.signal()
+	return true, dropped, nil
+}
+
+// victim picks the queued job to drop for a job of priority p, ok is false
+// if the pushed job is dropped instead
+func (q *MemoryQueue) victim(p RefreshPriority) (member string, ok bool) {
+	lowest := p
+	if len(q.jobs[PriorityPeriodic]) > 0 {
+		lowest = PriorityPeriodic
+	}
+	jobs := q.jobs[lowest]
+	switch {
+	case len(jobs) == 0:
+		return "", false
+	case p < lowest && q.policy == DropNewest:
+		return jobs[len(jobs)-1], true
+	case p < lowest || q.policy == DropOldest:
+		return jobs[0], true
+	}
+	return "", false
+}
+
+// Pop waits for the next job of priority upTo or a more urgent one until ctx
+// is done
+func (q *MemoryQueue) Pop(ctx context.Context, upTo RefreshPriority) (RefreshJob, error) {
+	for {
+		q.mu.Lock()
+		job, ok := q.pop(upTo)
+		wake := q.wake
+		q.mu.Unlock()
+		if ok {
+			return job, nil
+		}
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return RefreshJob{}, ctx.Err()
+		}
+	}
+}
+
+// TryPop returns the next job without waiting
+func (q *MemoryQueue) TryPop() (RefreshJob, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.pop(numPriorities - 1)
+	return job, ok, nil
+}
+
+// pop removes the next job of priority upTo or a more urgent one. q.mu must
+// be held.
+func (q *MemoryQueue) pop(upTo RefreshPriority) (RefreshJob, bool) {
+	for p := RefreshPriority(0); p <= upTo && p < numPriorities; p++ {
+		if len(q.jobs[p]) == 0 {
+			continue
+		}
+		member := q.jobs[p][0]
+		q.jobs[p] = q.jobs[p][1:]
+		job := q.queued[member]
+		delete(q.queued, member)
+		return job, true
+	}
+	return RefreshJob{}, false
+}
+
+// Stats returns the length of the queue
+func (q *MemoryQueue) Stats() (QueueStats, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return QueueStats{
+		Backend:    "memory",
+		OnDemand:   int64(len(q.jobs[PriorityOnDemand])),
+		Periodic:   int64(len(q.jobs[PriorityPeriodic])),
+		MaxLength:  q.maxLen,
+		DropPolicy: q.policy,
+		Dropped:    q.dropped,
+	}, nil
+}
+
+// RunRefreshWorkers runs workers goroutines passing the jobs of q of priority
+// upTo or a more urgent one to fn until ctx is done, and returns once all of
+// them stopped. Workers pause for a second when q fails, e.g. while Redis is
+// down.
+func RunRefreshWorkers(ctx context.Context, q RefreshQueue, upTo RefreshPriority, workers int, fn func(ctx context.Context, job RefreshJob)) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := q.Pop(ctx, upTo)
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					log.Printf("Failed to get refresh job: %v", err)
+					select {
+					case <-time.After(time.Second):
+					case <-ctx.Done():
+						return
+					}
+					continue
+				}
+				fn(ctx, job)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Keys of the refresh queue. The {queue} hash tag keeps them in one slot of a
+// Redis Cluster, so the push script may use all of them.
+const (
+	refreshQueueKey  = "refresh:{queue}:"
+	refreshQueuedKey = "refresh:{queue}:queued"
+	refreshRunsKey   = "refresh:{queue}:runs"
+)
+
+// popInterval is how often a waiting Pop looks for jobs. Scripts can't
+// block, and BLPOP can't clear the popped job from the hashes at once.
+const popInterval = 250 * time.Millisecond
+
+// pushScript is MemoryQueue.Push in Lua. KEYS are the lists of both
+// priorities, the hash of queued players with their priority and the hash of
+// their runs. ARGV are the player, its priority, the maximum length, the drop
+// policy and the run. It returns 1 if the job was queued, 0 if the player
+// already was and -1 if the job was dropped, followed by the player, priority
+// and run of the job dropped for it, if any.
+var pushScript = redis.NewScript(`
+local lists = {KEYS[1], KEYS[2]}
+local member, prio = ARGV[1], tonumber(ARGV[2])
+local cur = redis.call('HGET', KEYS[3], member)
+if cur then
+	cur = tonumber(cur)
+	-- Queued players are in the list of their priority, popScript clears
+	-- both at once
+	if cur <= prio or redis.call('LREM', lists[cur + 1], 1, member) == 0 then
+		return {0}
+	end
+	redis.call('RPUSH', lists[prio + 1], member)
+	redis.call('HSET', KEYS[3], member, prio)
+	return {1}
+end
+
+local dropped = {}
+local max = tonumber(ARGV[3])
+if max > 0 and redis.call('HLEN', KEYS[3]) >= max then
+	local lowest = prio
+	if redis.call('LLEN', KEYS[2]) > 0 then
+		lowest = 1
+	end
+	local victim
+	if prio < lowest and ARGV[4] == 'newest' then
+		victim = redis.call('RPOP', lists[lowest + 1])
+	elseif prio < lowest or ARGV[4] == 'oldest' then
+		victim = redis.call('LPOP', lists[lowest + 1])
+	end
+	if not victim then
+		return {-1}
+	end
+	local run = redis.call('HGET', KEYS[4], victim) or ''
+	redis.call('HDEL', KEYS[3], victim)
+	redis.call('HDEL', KEYS[4], victim)
+	dropped = {victim, lowest, run}
+end
+
+redis.call('RPUSH', lists[prio + 1], member)
+redis.call('HSET', KEYS[3], member, prio)
+if ARGV[5] ~= '' then
+	redis.call('HSET', KEYS[4], member, ARGV[5])
+end
+return {1, unpack(dropped)}
+`)
+
+// popScript pops the next job of the first ARGV[1] lists of KEYS and clears
+// it from the hashes of queued players and their runs, the last two KEYS. It
+// returns the player, the priority and the run of the job, or nil.
+var popScript = redis.NewScript(`
+local queued, runs = KEYS[#KEYS - 1], KEYS[#KEYS]
+for i = 1, tonumber(ARGV[1]) do
+	local member = redis.call('LPOP', KEYS[i])
+	if member then
+		local run = redis.call('HGET', runs, member) or ''
+		redis.call('HDEL', queued, member)
+		redis.call('HDEL', runs, member)
+		return {member, i - 1, run}
+	end
+end
+return false
+`)
+
+// RedisQueue is a RefreshQueue in Redis lists, shared by the API and the
+// scraper
+type RedisQueue struct {
+	cache   *RedisCache
+	maxLen  int
+	policy  DropPolicy
+	dropped int64
+}
+
+// RefreshQueue returns the refresh queue in this Redis. maxLen and policy
+// apply to the jobs pushed through it.
+func (c *RedisCache) RefreshQueue(maxLen int, policy DropPolicy) *RedisQueue {
+	return &RedisQueue{cache: c, maxLen: maxLen, policy: policy}
+}
+
+// lists returns the keys of the lists of all priorities, in order
+func (q *RedisQueue) lists() []string {
+	keys := make([]string, numPriorities)
+	for p := range keys {
+		keys[p] = q.cache.key(refreshQueueKey + RefreshPriority(p).String())
+	}
+	return keys
+}
+
+// keys returns the keys of the lists and hashes of the queue
+func (q *RedisQueue) keys() []string {
+	return append(q.lists(), q.cache.key(refreshQueuedKey), q.cache.key(refreshRunsKey))
+}
+
+// Push queues a job, see RefreshQueue
+func (q *RedisQueue) Push(job RefreshJob) (bool, *RefreshJob, error) {
+	keys := q.keys()
+	res, err := pushScript.Run(q.cache.ctx, q.cache.client, keys,
+		job.member(), int(job.Priority), q.maxLen, string(q.policy), job.Run).Slice()
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to queue refresh: %w", err)
+	}
+
+	switch res[0].(int64) {
+	case -1:
+		atomic.AddInt64(&q.dropped, 1)
+		return false, nil, ErrQueueFull
+	case 0:
+		return false, nil, nil
+	}
+	if len(res) < 4 {
+		return true, nil, nil
+	}
+	atomic.AddInt64(&q.dropped, 1)
+	dropped := jobFromMember(res[1].(string), RefreshPriority(res[2].(int64)), res[3].(string))
+	return true, &dropped, nil
+}
+
+// Pop waits for the next job of priority upTo or a more urgent one until ctx
+// is done
+func (q *RedisQueue) Pop(ctx context.Context, upTo RefreshPriority) (RefreshJob, error) {
+	for {
+		job, ok, err := q.pop(upTo)
+		if ok || err != nil {
+			return job, err
+		}
+		select {
+		case <-time.After(popInterval):
+		case <-ctx.Done():
+			return RefreshJob{}, ctx.Err()
+		}
+	}
+}
+
+// TryPop returns the next job without waiting
+func (q *RedisQueue) TryPop() (RefreshJob, bool, error) {
+	return q.pop(numPriorities - 1)
+}
+
+// pop removes the next job of priority upTo or a more urgent one, and allows
+// the player to be queued again
+func (q *RedisQueue) pop(upTo RefreshPriority) (RefreshJob, bool, error) {
+	n := min(upTo+1, numPriorities)
+	res, err := popScript.Run(q.cache.ctx, q.cache.client, q.keys(), int(n)).Slice()
+	if err == redis.Nil {
+		return RefreshJob{}, false, nil
+	}
+	if err != nil {
+		return RefreshJob{}, false, fmt.Errorf("failed to pop refresh job: %w", err)
+	}
+	return jobFromMember(res[0].(string), RefreshPriority(res[1].(int64)), res[2].(string)), true, nil
+}
+
+// Stats returns the length of the queue
+func (q *RedisQueue) Stats() (QueueStats, error) {
+	lists := q.lists()
+	lens := make([]*redis.IntCmd, len(lists))
+	_, err := q.cache.client.Pipelined(q.cache.ctx, func(pipe redis.Pipeliner) error {
+		for i, list := range lists {
+			lens[i] = pipe.LLen(q.cache.ctx, list)
+		}
+		return nil
+	})
+	if err != nil {
+		return QueueStats{}, fmt.Errorf("failed to get refresh queue stats: %w", err)
+	}
+
+	return QueueStats{
+		Backend:    "redis",
+		OnDemand:   lens[PriorityOnDemand].Val(),
+		Periodic:   lens[PriorityPeriodic].Val(),
+		MaxLength:  q.maxLen,
+		DropPolicy: q.policy,
+		Dropped:    atomic.LoadInt64(&q.dropped),
+	}, nil
+}
@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// popAll returns the tags of all queued jobs in the order they are popped
+func popAll(t *testing.T, q RefreshQueue) []string {
+	t.Helper()
+	var tags []string
+	for {
+		job, ok, err := q.TryPop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			return tags
+		}
+		tags = append(tags, job.Tag)
+	}
+}
+
+// queueBackends returns a constructor of every RefreshQueue backend
+func queueBackends(t *testing.T) map[string]func(maxLen int, policy DropPolicy) RefreshQueue {
+	return map[string]func(int, DropPolicy) RefreshQueue{
+		"memory": func(maxLen int, policy DropPolicy) RefreshQueue {
+			return NewMemoryQueue(maxLen, policy)
+		},
+		"redis": func(maxLen int, policy DropPolicy) RefreshQueue {
+			return newTestRedis(t).RefreshQueue(maxLen, policy)
+		},
+	}
+}
+
+func TestRefreshQueuePriority(t *testing.T) {
+	for name, newQueue := range queueBackends(t) {
+		q := newQueue(0, DropNewest)
+		q.Push(RefreshJob{Platform: "pc", Tag: "a-1", Priority: PriorityPeriodic})
+		q.Push(RefreshJob{Platform: "pc", Tag: "b-2", Priority: PriorityPeriodic, Run: "run1"})
+		q.Push(RefreshJob{Platform: "pc", Tag: "c-3", Priority: PriorityOnDemand})
+
+		// Duplicates are dropped, an on-demand job moves a periodic one
+		// ahead and keeps its run
+		if queued, _, _ := q.Push(RefreshJob{Platform: "pc", Tag: "c-3", Priority: PriorityPeriodic}); queued {
+			t.Errorf("%s: duplicate job was queued", name)
+		}
+		if queued, _, _ := q.Push(RefreshJob{Platform: "pc", Tag: "b-2", Priority: PriorityOnDemand}); !queued {
+			t.Errorf("%s: on-demand job for a periodic one was not queued", name)
+		}
+
+		want := []RefreshJob{
+			{Platform: "pc", Tag: "c-3", Priority: PriorityOnDemand},
+			{Platform: "pc", Tag: "b-2", Priority: PriorityOnDemand, Run: "run1"},
+			{Platform: "pc", Tag: "a-1", Priority: PriorityPeriodic},
+		}
+		for _, w := range want {
+			if job, ok, _ := q.TryPop(); !ok || job != w {
+				t.Errorf("%s: popped %+v; want %+v", name, job, w)
+			}
+		}
+
+		// Popped players may be queued again
+		if queued, _, _ := q.Push(RefreshJob{Platform: "pc", Tag: "a-1", Priority: PriorityPeriodic}); !queued {
+			t.Errorf("%s: popped player was not queued again", name)
+		}
+	}
+}
+
+func TestRefreshQueueDropPolicy(t *testing.T) {
+	tests := []struct {
+		policy  DropPolicy
+		push    RefreshPriority
+		err     error
+		want    []string
+		dropped int64
+	}{
+		{DropNewest, PriorityPeriodic, ErrQueueFull, []string{"a-1", "b-2"}, 1},
+		{DropOldest, PriorityPeriodic, nil, []string{"b-2", "c-3"}, 1},
+		{DropNewest, PriorityOnDemand, nil, []string{"c-3", "a-1"}, 1},
+		{DropOldest, PriorityOnDemand, nil, []string{"c-3", "b-2"}, 1},
+	}
+	for name, newQueue := range queueBackends(t) {
+		for _, tt := range tests {
+			q := newQueue(2, tt.policy)
+			q.Push(RefreshJob{Platform: "pc", Tag: "a-1", Priority: PriorityPeriodic, Run: "run1"})
+			q.Push(RefreshJob{Platform: "pc", Tag: "b-2", Priority: PriorityPeriodic, Run: "run1"})
+
+			_, dropped, err := q.Push(RefreshJob{Platform: "pc", Tag: "c-3", Priority: tt.push})
+			if err != tt.err {
+				t.Errorf("%s, %s, %s: Push error = %v; want %v", name, tt.policy, tt.push, err, tt.err)
+			}
+			if (dropped != nil) != (err == nil) || (dropped != nil && dropped.Run != "run1") {
+				t.Errorf("%s, %s, %s: dropped %+v", name, tt.policy, tt.push, dropped)
+			}
+			if st, _ := q.Stats(); st.Dropped != tt.dropped {
+				t.Errorf("%s, %s, %s: dropped %d; want %d", name, tt.policy, tt.push, st.Dropped, tt.dropped)
+			}
+			got := popAll(t, q)
+			if len(got) != 2 || got[0] != tt.want[0] || got[1] != tt.want[1] {
+				t.Errorf("%s, %s, %s: popped %v; want %v", name, tt.policy, tt.push, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestRedisQueuePopClearsPlayer(t *testing.T) {
+	rc := newTestRedis(t)
+	q := rc.RefreshQueue(1, DropNewest)
+	q.Push(RefreshJob{Platform: "pc", Tag: "a-1", Priority: PriorityPeriodic, Run: "run1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if job, err := q.Pop(ctx, PriorityPeriodic); err != nil || job.Run != "run1" {
+		t.Fatalf("Pop = %+v, %v; want the job of run1", job, err)
+	}
+
+	// Nothing of the popped job is left to block or fill the queue
+	for _, key := range []string{refreshQueuedKey, refreshRunsKey} {
+		if n, _ := rc.client.HLen(rc.ctx, rc.key(key)).Result(); n != 0 {
+			t.Errorf("%s holds %d players after the pop; want 0", key, n)
+		}
+	}
+	if _, _, err := q.Push(RefreshJob{Platform: "pc", Tag: "b-2", Priority: PriorityPeriodic}); err != nil {
+		t.Errorf("Push after the pop: %v", err)
+	}
+}
+
+func TestRunRefreshWorkers(t *testing.T) {
+	q := NewMemoryQueue(0, DropNewest)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	done := make(map[string]bool)
+	finished := make(chan struct{})
+	go func() {
+		RunRefreshWorkers(ctx, q, PriorityPeriodic, 3, func(_ context.Context, job RefreshJob) {
+			mu.Lock()
+			defer mu.Unlock()
+			done[job.Tag] = true
+			if len(done) == 10 {
+				cancel()
+			}
+		})
+		close(finished)
+	}()
+
+	for _, tag := range []string{"a-1", "b-2", "c-3", "d-4", "e-5", "f-6", "g-7", "h-8", "i-9", "j-10"} {
+		q.Push(RefreshJob{Platform: "pc", Tag: tag, Priority: PriorityOnDemand})
+	}
+	<-finished
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(done) != 10 {
+		t.Errorf("refreshed %d players; want 10", len(done))
+	}
+}
+
+func TestRunRefreshWorkersOnDemandOnly(t *testing.T) {
+	q := NewMemoryQueue(0, DropNewest)
+	q.Push(RefreshJob{Platform: "pc", Tag: "a-1", Priority: PriorityPeriodic})
+	q.Push(RefreshJob{Platform: "pc", Tag: "b-2", Priority: PriorityOnDemand})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var got []string
+	RunRefreshWorkers(ctx, q, PriorityOnDemand, 1, func(_ context.Context, job RefreshJob) {
+		got = append(got, job.Tag)
+		cancel()
+	})
+
+	if len(got) != 1 || got[0] != "b-2" {
+		t.Errorf("refreshed %v; want [b-2]", got)
+	}
+	if left := popAll(t, q); len(left) != 1 || left[0] != "a-1" {
+		t.Errorf("left %v in the queue; want [a-1]", left)
+	}
+}
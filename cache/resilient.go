@@ -0,0 +1,409 @@
+package cache
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Domekologe/ow-api/ovrstat"
+)
+
+// ErrUnavailable is returned by a ResilientStore while Redis is down and
+// there is no fallback
+var ErrUnavailable = errors.New("cache unavailable")
+
+// States of a ResilientStore
+const (
+	StateConnected = "connected"
+	StateDegraded  = "degraded"
+)
+
+const (
+	// healthInterval is how often a connected ResilientStore pings Redis
+	healthInterval = 5 * time.Second
+
+	// minBackoff is the first delay between reconnect attempts, it doubles
+	// up to the configured maximum
+	minBackoff = 500 * time.Millisecond
+)
+
+// ResilientStore serves from Redis while it is reachable and from a
+// fallback, usually a MemoryStore, while it isn't. It pings Redis in the
+// background, with backoff while it is down, and switches back on its own
+// once it answers again. The go-redis client reconnects by itself, so Redis
+// may well be down when the store is created.
+type ResilientStore struct {
+	redis    *RedisCache
+	primary  Store
+	fallback Store
+
+	maxBackoff time.Duration
+
+	mu       sync.Mutex
+	state    string
+	since    time.Time
+	lastErr  error
+	attempts int
+
+	// wake asks the health loop to ping now, e.g. after a failed command
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// ConnectionState describes the connection of a ResilientStore to Redis
+type ConnectionState struct {
+	State string    `json:"state"`
+	Since time.Time `json:"since"`
+
+	// LastError and Attempts describe the reconnect attempts while degraded
+	LastError string `json:"last_error,omitempty"`
+	Attempts  int    `json:"attempts,omitempty"`
+}
+
+// NewResilientStore serves primary, which is rc or wraps it, while rc is
+// reachable and fallback otherwise. fallback may be nil, the store then
+// returns ErrUnavailable while Redis is down. Reconnect attempts back off up
+// to maxBackoff.
+func NewResilientStore(rc *RedisCache, primary, fallback Store, maxBackoff time.Duration) *ResilientStore {
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff
+	}
+	s := &ResilientStore{
+		redis:      rc,
+		primary:    primary,
+		fallback:   fallback,
+		maxBackoff: maxBackoff,
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	// Start in the right state, so a reachable Redis is used right away
+	if err := rc.Ping(); err != nil {
+		s.setDegraded(err)
+	} else {
+		s.setConnected()
+	}
+	go s.run()
+	return s
+}
+
+// run pings Redis until the store is closed
+func (s *ResilientStore) run() {
+	defer close(s.done)
+
+	backoff := minBackoff
+	for {
+		wait := healthInterval
+		if !s.isConnected() {
+			wait = backoff
+			backoff *= 2
+			if backoff > s.maxBackoff {
+				backoff = s.maxBackoff
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+
+		if err := s.redis.Ping(); err != nil {
+			s.setDegraded(err)
+			continue
+		}
+		if s.setConnected() {
+			backoff = minBackoff
+		}
+	}
+}
+
+// setConnected switches to Redis. It reports whether the store was degraded
+// before.
+func (s *ResilientStore) setConnected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state == StateConnected {
+		return false
+	}
+	recovered := s.state == StateDegraded
+	s.state, s.since = StateConnected, time.Now()
+	s.lastErr, s.attempts = nil, 0
+
+	if recovered {
+		log.Printf("Redis is reachable again, leaving degraded mode")
+		// What was cached meanwhile may be older than Redis by the next
+		// outage
+		if s.fallback != nil {
+			s.fallback.Flush()
+		}
+	}
+	return recovered
+}
+
+// setDegraded switches to the fallback after a failed ping
+func (s *ResilientStore) setDegraded(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastErr = err
+	s.attempts++
+	if s.state == StateDegraded {
+		return
+	}
+	s.state, s.since = StateDegraded, time.Now()
+	if s.fallback != nil {
+		log.Printf("Warning: Redis is unreachable, using the in-memory cache until it is back: %v", err)
+	} else {
+		log.Printf("Warning: Redis is unreachable, continuing without cache until it is back: %v", err)
+	}
+}
+
+// isConnected reports whether Redis is in use
+func (s *ResilientStore) isConnected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state == StateConnected
+}
+
+// State returns the current connection state
+func (s *ResilientStore) State() ConnectionState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := ConnectionState{State: s.state, Since: s.since, Attempts: s.attempts}
+	if s.lastErr != nil {
+		st.LastError = s.lastErr.Error()
+	}
+	return st
+}
+
+// Available reports whether entries can be read and written right now
+func (s *ResilientStore) Available() bool {
+	return s.isConnected() || s.fallback != nil
+}
+
+// store returns the Store to use right now
+func (s *ResilientStore) store() (Store, error) {
+	if s.isConnected() {
+		return s.primary, nil
+	}
+	if s.fallback != nil {
+		return s.fallback, nil
+	}
+	return nil, ErrUnavailable
+}
+
+// check passes err through. An error from Redis makes the health loop check
+// the connection right away instead of at the next interval.
+func (s *ResilientStore) check(st Store, err error) error {
+	if err != nil && st == s.primary {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	return err
+}
+
+// Get retrieves cached player stats
+func (s *ResilientStore) Get(platform, tag string) (*ovrstat.PlayerStats, error) {
+	st, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := st.Get(platform, tag)
+	return stats, s.check(st, err)
+}
+
+// GetProfile retrieves cached player profile stats
+func (s *ResilientStore) GetProfile(platform, tag string) (*ovrstat.PlayerStatsProfile, error) {
+	st, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := st.GetProfile(platform, tag)
+	return stats, s.check(st, err)
+}
+
+// GetEntry retrieves cached player stats and when they were fetched
+func (s *ResilientStore) GetEntry(platform, tag string) (*ovrstat.PlayerStats, time.Time, error) {
+	st, err := s.store()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	stats, fetchedAt, err := st.GetEntry(platform, tag)
+	return stats, fetchedAt, s.check(st, err)
+}
+
+// GetProfileEntry retrieves cached player profile stats and when they were
+// fetched
+func (s *ResilientStore) GetProfileEntry(platform, tag string) (*ovrstat.PlayerStatsProfile, time.Time, error) {
+	st, err := s.store()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	stats, fetchedAt, err := st.GetProfileEntry(platform, tag)
+	return stats, fetchedAt, s.check(st, err)
+}
+
+// Set stores player stats
+func (s *ResilientStore) Set(platform, tag string, stats *ovrstat.PlayerStats) error {
+	st, err := s.store()
+	if err != nil {
+		return err
+	}
+	return s.check(st, st.Set(platform, tag, stats))
+}
+
+// SetProfile stores player profile stats
+func (s *ResilientStore) SetProfile(platform, tag string, stats *ovrstat.PlayerStatsProfile) error {
+	st, err := s.store()
+	if err != nil {
+		return err
+	}
+	return s.check(st, st.SetProfile(platform, tag, stats))
+}
+
+// SetNotFound remembers that a player doesn't exist
+func (s *ResilientStore) SetNotFound(platform, tag string) error {
+	st, err := s.store()
+	if err != nil {
+		return err
+	}
+	return s.check(st, st.SetNotFound(platform, tag))
+}
+
+// Extend keeps the stats of a player for at least ttl. The fallback keeps
+// them as long as it does.
+func (s *ResilientStore) Extend(platform, tag string, ttl time.Duration) error {
+	st, err := s.store()
+	if err != nil {
+		return err
+	}
+	e, ok := st.(Extender)
+	if !ok {
+		return nil
+	}
+	return s.check(st, e.Extend(platform, tag, ttl))
+}
+
+// Negative returns the negative entry of a player, if any
+func (s *ResilientStore) Negative(platform, tag string) (string, time.Time, error) {
+	st, err := s.store()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	kind, since, err := st.Negative(platform, tag)
+	return kind, since, s.check(st, err)
+}
+
+// Inspect describes all entries of a player
+func (s *ResilientStore) Inspect(platform, tag string) (*PlayerEntries, error) {
+	st, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := st.Inspect(platform, tag)
+	return entries, s.check(st, err)
+}
+
+// CareerID returns the career ID tag resolved to last
+func (s *ResilientStore) CareerID(tag string) (string, error) {
+	st, err := s.store()
+	if err != nil {
+		return "", err
+	}
+	id, err := st.CareerID(tag)
+	return id, s.check(st, err)
+}
+
+// SetCareerID remembers the career ID tag resolves to
+func (s *ResilientStore) SetCareerID(tag, careerID string) error {
+	st, err := s.store()
+	if err != nil {
+		return err
+	}
+	return s.check(st, st.SetCareerID(tag, careerID))
+}
+
+// Career returns what is known about a career page
+func (s *ResilientStore) Career(careerID string) (*Career, error) {
+	st, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	career, err := st.Career(careerID)
+	return career, s.check(st, err)
+}
+
+// SetCareer stores what is known about a career page
+func (s *ResilientStore) SetCareer(c *Career) error {
+	st, err := s.store()
+	if err != nil {
+		return err
+	}
+	return s.check(st, st.SetCareer(c))
+}
+
+// Delete removes all cached entries of a player
+func (s *ResilientStore) Delete(platform, tag string) error {
+	st, err := s.store()
+	if err != nil {
+		return err
+	}
+	return s.check(st, st.Delete(platform, tag))
+}
+
+// ScanKeys calls fn with every key matching pattern
+func (s *ResilientStore) ScanKeys(pattern string, fn func(key string) error) error {
+	st, err := s.store()
+	if err != nil {
+		return err
+	}
+	return s.check(st, st.ScanKeys(pattern, fn))
+}
+
+// Flush removes every entry of the store in use
+func (s *ResilientStore) Flush() error {
+	st, err := s.store()
+	if err != nil {
+		return err
+	}
+	return s.check(st, st.Flush())
+}
+
+// Stats returns the stats of the store in use and the connection state
+func (s *ResilientStore) Stats() (StoreStats, error) {
+	conn := s.State()
+	st, err := s.store()
+	if err != nil {
+		return StoreStats{Backend: "none", Connection: &conn}, nil
+	}
+	stats, err := st.Stats()
+	stats.Connection = &conn
+	return stats, s.check(st, err)
+}
+
+// TTL returns how long entries are kept
+func (s *ResilientStore) TTL() time.Duration {
+	return s.primary.TTL()
+}
+
+// Close stops the health checks and closes both stores
+func (s *ResilientStore) Close() error {
+	close(s.stop)
+	<-s.done
+	if s.fallback != nil {
+		s.fallback.Close()
+	}
+	return s.primary.Close()
+}
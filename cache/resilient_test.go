@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Domekologe/ow-api/ovrstat"
+)
+
+// unreachableRedis returns a RedisCache pointing at a port nothing listens on
+func unreachableRedis(t *testing.T) *RedisCache {
+	t.Helper()
+	rc, err := DialRedis(RedisOptions{Host: "127.0.0.1", Port: 1, DialTimeout: 100 * time.Millisecond}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rc
+}
+
+func TestResilientStoreDegraded(t *testing.T) {
+	rc := unreachableRedis(t)
+	fallback := NewMemoryStore(10, time.Hour)
+	s := NewResilientStore(rc, rc, fallback, time.Second)
+	defer s.Close()
+
+	if st := s.State(); st.State != StateDegraded || st.LastError == "" {
+		t.Fatalf("State = %+v; want degraded with an error", st)
+	}
+	if !s.Available() {
+		t.Error("Available = false with a fallback")
+	}
+
+	if err := s.Set("pc", "a-1", &ovrstat.PlayerStats{Name: "A"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := fallback.Get("pc", "a-1"); got == nil || got.Name != "A" {
+		t.Errorf("fallback Get = %+v; want the stats written while degraded", got)
+	}
+
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Backend != "memory" || stats.Connection == nil || stats.Connection.State != StateDegraded {
+		t.Errorf("Stats = %+v; want memory backend and degraded connection", stats)
+	}
+}
+
+func TestResilientStoreNoFallback(t *testing.T) {
+	rc := unreachableRedis(t)
+	s := NewResilientStore(rc, rc, nil, time.Second)
+	defer s.Close()
+
+	if s.Available() {
+		t.Error("Available = true without Redis and fallback")
+	}
+	if _, err := s.Get("pc", "a-1"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Get error = %v; want ErrUnavailable", err)
+	}
+}
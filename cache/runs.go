@@ -0,0 +1,348 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Statuses of a ScraperRun
+const (
+	RunPending = "pending"
+	RunRunning = "running"
+	RunDone    = "done"
+	RunFailed  = "failed"
+)
+
+// Triggers of a ScraperRun
+const (
+	TriggerSchedule = "schedule"
+	TriggerAdmin    = "admin"
+)
+
+// runTTL is how long ScraperRun records are kept
+const runTTL = 24 * time.Hour
+
+// scraperTriggerChannel carries the IDs of runs an admin asked the scraper
+// for. It is relative to the key prefix.
+const scraperTriggerChannel = "scraper:trigger"
+
+// ErrNoScraper is returned by TriggerScraper when no scraper is listening
+var ErrNoScraper = errors.New("no scraper is listening")
+
+// ErrRunExpired is reported to Done for the jobs left when the deadline of
+// their run passed
+var ErrRunExpired = errors.New("scraper run deadline passed")
+
+// maxDeadlines caps the deadlines ScraperRuns remembers
+const maxDeadlines = 1000
+
+// RunTarget selects the players of a ScraperRun. The zero value selects every
+// cached player.
+type RunTarget struct {
+	Platform string `json:"platform,omitempty"`
+	// Tag, together with Platform, selects one player. It is a canonical
+	// key tag, see ovrstat.PlayerID.Key.
+	Tag string `json:"tag,omitempty"`
+	// Pattern selects the players whose keys match a ScanKeys pattern,
+	// e.g. "stats:pc:a*"
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// String describes t for logs
+func (t RunTarget) String() string {
+	switch {
+	case t.Tag != "":
+		return "player " + t.Platform + ":" + t.Tag
+	case t.Pattern != "":
+		return "pattern " + t.Pattern
+	case t.Platform != "":
+		return "platform " + t.Platform
+	}
+	return "all players"
+}
+
+// ScraperRun is one pass of the scraper over the players of its target. The
+// scraper starts it, whoever refreshes its jobs reports their outcome.
+type ScraperRun struct {
+	ID      string    `json:"id"`
+	Trigger string    `json:"trigger"`
+	Target  RunTarget `json:"target"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+
+	RequestedAt time.Time  `json:"requested_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	// Deadline is when the jobs left are given up
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// DurationSeconds is how long the run took, or runs so far
+	DurationSeconds float64 `json:"duration_seconds"`
+
+	// Total players, and how many of them were refreshed, failed, were
+	// skipped because they were already queued or recently negative, were
+	// dropped from a full queue or were left at the deadline
+	Total     int64 `json:"total"`
+	Succeeded int64 `json:"succeeded"`
+	Failed    int64 `json:"failed"`
+	Skipped   int64 `json:"skipped"`
+	Dropped   int64 `json:"dropped"`
+	Expired   int64 `json:"expired"`
+}
+
+// progressScript adds ARGV[2] to the counter ARGV[1] of the run KEYS[1] and
+// finishes the run at time ARGV[3] once every player is accounted for. It
+// ignores runs that expired.
+var progressScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+if tonumber(ARGV[2]) > 0 then
+	redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[2])
+end
+local f = redis.call('HMGET', KEYS[1], 'status', 'total', 'succeeded', 'failed', 'skipped', 'dropped', 'expired')
+if f[1] ~= 'running' then
+	return 0
+end
+local done = 0
+for i = 3, 7 do
+	done = done + (tonumber(f[i]) or 0)
+end
+if done < tonumber(f[2]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'status', 'done', 'finished_at', ARGV[3])
+return 1
+`)
+
+// ScraperRuns records the runs of the scraper in Redis
+type ScraperRuns struct {
+	cache *RedisCache
+
+	// deadlines caches the deadlines of started runs, they don't change
+	mu        sync.Mutex
+	deadlines map[string]time.Time
+}
+
+// ScraperRuns returns the run records in this Redis
+func (c *RedisCache) ScraperRuns() *ScraperRuns {
+	return &ScraperRuns{cache: c, deadlines: make(map[string]time.Time)}
+}
+
+// key returns the key of the run id
+func (r *ScraperRuns) key(id string) string {
+	return r.cache.key("scraper:run:" + id)
+}
+
+// Create records a new pending run
+func (r *ScraperRuns) Create(trigger string, target RunTarget) (*ScraperRun, error) {
+	run := &ScraperRun{
+		ID:          newOrigin(),
+		Trigger:     trigger,
+		Target:      target,
+		Status:      RunPending,
+		RequestedAt: time.Now(),
+	}
+	data, err := json.Marshal(target)
+	if err != nil {
+		return nil, err
+	}
+
+	key := r.key(run.ID)
+	_, err = r.cache.client.TxPipelined(r.cache.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(r.cache.ctx, key,
+			"id", run.ID,
+			"trigger", trigger,
+			"target", string(data),
+			"status", RunPending,
+			"requested_at", run.RequestedAt.Format(time.RFC3339Nano))
+		pipe.Expire(r.cache.ctx, key, runTTL)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scraper run: %w", err)
+	}
+	return run, nil
+}
+
+// Trigger records a run an admin asked for and announces it to the scraper
+func (r *ScraperRuns) Trigger(target RunTarget) (*ScraperRun, error) {
+	run, err := r.Create(TriggerAdmin, target)
+	if err != nil {
+		return nil, err
+	}
+
+	receivers, err := r.cache.client.Publish(r.cache.ctx, r.cache.key(scraperTriggerChannel), run.ID).Result()
+	if err == nil && receivers == 0 {
+		err = ErrNoScraper
+	}
+	if err != nil {
+		r.Fail(run.ID, err)
+		return nil, err
+	}
+	return run, nil
+}
+
+// Subscribe calls fn with the runs admins trigger. The subscription
+// reconnects on its own and ends when the returned function is called.
+func (r *ScraperRuns) Subscribe(fn func(run *ScraperRun)) func() error {
+	sub := r.cache.client.Subscribe(r.cache.ctx, r.cache.key(scraperTriggerChannel))
+	go func() {
+		for msg := range sub.Channel() {
+			run, err := r.Get(msg.Payload)
+			if err != nil || run == nil {
+				continue
+			}
+			fn(run)
+		}
+	}()
+	return sub.Close
+}
+
+// Get returns the run id, or nil if there is none
+func (r *ScraperRuns) Get(id string) (*ScraperRun, error) {
+	f, err := r.cache.client.HGetAll(r.cache.ctx, r.key(id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scraper run: %w", err)
+	}
+	if len(f) == 0 {
+		return nil, nil
+	}
+
+	run := &ScraperRun{
+		ID:      f["id"],
+		Trigger: f["trigger"],
+		Status:  f["status"],
+		Error:   f["error"],
+	}
+	json.Unmarshal([]byte(f["target"]), &run.Target)
+	run.RequestedAt, _ = time.Parse(time.RFC3339Nano, f["requested_at"])
+	if t, err := time.Parse(time.RFC3339Nano, f["started_at"]); err == nil {
+		run.StartedAt = &t
+	}
+	if t, err := time.Parse(time.RFC3339Nano, f["finished_at"]); err == nil {
+		run.FinishedAt = &t
+	}
+	if t, err := time.Parse(time.RFC3339Nano, f["deadline"]); err == nil {
+		run.Deadline = &t
+	}
+	if run.StartedAt != nil {
+		end := time.Now()
+		if run.FinishedAt != nil {
+			end = *run.FinishedAt
+		}
+		run.DurationSeconds = end.Sub(*run.StartedAt).Seconds()
+	}
+	for name, v := range map[string]*int64{
+		"total":     &run.Total,
+		"succeeded": &run.Succeeded,
+		"failed":    &run.Failed,
+		"skipped":   &run.Skipped,
+		"dropped":   &run.Dropped,
+		"expired":   &run.Expired,
+	} {
+		*v, _ = strconv.ParseInt(f[name], 10, 64)
+	}
+	return run, nil
+}
+
+// Start marks a run as running over total players. Its jobs are given up
+// after timeout, 0 lets it run until all are done.
+func (r *ScraperRuns) Start(id string, total int, timeout time.Duration) error {
+	now := time.Now()
+	fields := []interface{}{
+		"status", RunRunning,
+		"started_at", now.Format(time.RFC3339Nano),
+		"total", total,
+	}
+	if timeout > 0 {
+		fields = append(fields, "deadline", now.Add(timeout).Format(time.RFC3339Nano))
+	}
+	if err := r.cache.client.HSet(r.cache.ctx, r.key(id), fields...).Err(); err != nil {
+		return fmt.Errorf("failed to start scraper run: %w", err)
+	}
+	return nil
+}
+
+// Expired reports whether the deadline of run id passed. Jobs without a run
+// never expire.
+func (r *ScraperRuns) Expired(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	r.mu.Lock()
+	deadline, ok := r.deadlines[id]
+	r.mu.Unlock()
+	if !ok {
+		f, err := r.cache.client.HMGet(r.cache.ctx, r.key(id), "status", "deadline").Result()
+		if err != nil {
+			return false
+		}
+		status, _ := f[0].(string)
+		v, _ := f[1].(string)
+		deadline, _ = time.Parse(time.RFC3339Nano, v)
+
+		// Start sets the deadline, until then it is read again
+		if status == "" || status == RunPending {
+			return false
+		}
+		r.mu.Lock()
+		if len(r.deadlines) >= maxDeadlines {
+			clear(r.deadlines)
+		}
+		r.deadlines[id] = deadline
+		r.mu.Unlock()
+	}
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// Skip counts n players of a run as skipped. It also finishes runs with
+// nothing left to do.
+func (r *ScraperRuns) Skip(id string, n int) error {
+	return r.progress(id, "skipped", n)
+}
+
+// Done records the outcome of the job of a run. Jobs dropped with
+// ErrQueueFull or given up with ErrRunExpired are counted apart from failed
+// ones.
+func (r *ScraperRuns) Done(id string, err error) error {
+	switch {
+	case err == nil:
+		return r.progress(id, "succeeded", 1)
+	case errors.Is(err, ErrQueueFull):
+		return r.progress(id, "dropped", 1)
+	case errors.Is(err, ErrRunExpired):
+		return r.progress(id, "expired", 1)
+	}
+	return r.progress(id, "failed", 1)
+}
+
+// progress adds n to the counter field of a run
+func (r *ScraperRuns) progress(id, field string, n int) error {
+	if id == "" {
+		return nil
+	}
+	now := time.Now().Format(time.RFC3339Nano)
+	if err := progressScript.Run(r.cache.ctx, r.cache.client, []string{r.key(id)}, field, n, now).Err(); err != nil {
+		return fmt.Errorf("failed to update scraper run: %w", err)
+	}
+	return nil
+}
+
+// Fail ends a run that couldn't be carried out
+func (r *ScraperRuns) Fail(id string, cause error) error {
+	err := r.cache.client.HSet(r.cache.ctx, r.key(id),
+		"status", RunFailed,
+		"error", cause.Error(),
+		"finished_at", time.Now().Format(time.RFC3339Nano)).Err()
+	if err != nil {
+		return fmt.Errorf("failed to update scraper run: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/Domekologe/ow-api/ovrstat"
+)
+
+// Store caches the stats of players. Keys follow the same layout in every
+// implementation (stats:platform:tag, stats:platform:tag:profile and
+// negative:kind:platform:tag, see ParseKey), so ScanKeys patterns work the
+// same everywhere. In Redis they are namespaced by a key prefix.
+type Store interface {
+	// Get and GetProfile return nil stats on a cache miss
+	Get(platform, tag string) (*ovrstat.PlayerStats, error)
+	GetProfile(platform, tag string) (*ovrstat.PlayerStatsProfile, error)
+
+	// GetEntry and GetProfileEntry also return when the stats were fetched
+	GetEntry(platform, tag string) (*ovrstat.PlayerStats, time.Time, error)
+	GetProfileEntry(platform, tag string) (*ovrstat.PlayerStatsProfile, time.Time, error)
+
+	Set(platform, tag string, stats *ovrstat.PlayerStats) error
+	SetProfile(platform, tag string, stats *ovrstat.PlayerStatsProfile) error
+
+	// SetNotFound remembers that a player doesn't exist and drops their
+	// stats. It is a no-op when not-found entries are disabled.
+	SetNotFound(platform, tag string) error
+
+	// Negative returns NegativeNotFound or NegativePrivate when a player is
+	// remembered as such, and since when. kind is empty otherwise.
+	Negative(platform, tag string) (kind string, since time.Time, err error)
+
+	// Inspect describes all entries of a player, for admins
+	Inspect(platform, tag string) (*PlayerEntries, error)
+
+	// CareerID returns the career ID tag resolved to last, or "" when unknown
+	CareerID(tag string) (string, error)
+
+	// SetCareerID remembers the career ID tag resolves to. It is a no-op
+	// when career IDs are disabled.
+	SetCareerID(tag, careerID string) error
+
+	// Career returns what is known about a career page, or nil
+	Career(careerID string) (*Career, error)
+	SetCareer(c *Career) error
+
+	// Delete removes all entries of a player, negative ones included
+	Delete(platform, tag string) error
+
+	// ScanKeys calls fn with every key matching a glob pattern such as
+	// StatsKeyPattern. It stops at the first error fn returns.
+	ScanKeys(pattern string, fn func(key string) error) error
+
+	// Flush removes every entry, and nothing else
+	Flush() error
+
+	Stats() (StoreStats, error)
+
+	// TTL is how long entries are kept
+	TTL() time.Duration
+
+	Close() error
+}
+
+// Extender is implemented by the stores that can keep the entries of a
+// player longer, see RedisCache.Extend
+type Extender interface {
+	Extend(platform, tag string, ttl time.Duration) error
+}
+
+// StoreStats describes the state of a Store
+type StoreStats struct {
+	Backend    string `json:"backend"`
+	Entries    int64  `json:"entries"`
+	MaxEntries int    `json:"max_entries,omitempty"`
+	Evictions  int64  `json:"evictions,omitempty"`
+	KeyPrefix  string `json:"key_prefix,omitempty"`
+
+	// Set by TieredStore only
+	L1Entries int64 `json:"l1_entries,omitempty"`
+	L1Hits    int64 `json:"l1_hits,omitempty"`
+	L2Hits    int64 `json:"l2_hits,omitempty"`
+	Misses    int64 `json:"misses,omitempty"`
+
+	// Set by ResilientStore only
+	Connection *ConnectionState `json:"connection,omitempty"`
+}
+
+// Compile time checks that all backends satisfy Store
+var (
+	_ Store = (*RedisCache)(nil)
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*TieredStore)(nil)
+	_ Store = (*ResilientStore)(nil)
+
+	_ Extender = (*RedisCache)(nil)
+	_ Extender = (*TieredStore)(nil)
+	_ Extender = (*ResilientStore)(nil)
+)
+
+// entryTTLs holds how long entries other than regular stats are kept. It is
+// embedded by the stores.
+type entryTTLs struct {
+	notFound time.Duration
+	private  time.Duration
+	career   time.Duration
+}
+
+// SetNegativeTTLs sets how long not-found players and private profiles are
+// remembered. A zero notFound disables not-found entries, a zero private
+// keeps private profiles for the regular TTL.
+func (n *entryTTLs) SetNegativeTTLs(notFound, private time.Duration) {
+	n.notFound = notFound
+	n.private = private
+}
+
+// SetCareerTTL sets how long the career IDs of players are remembered. Zero
+// disables it.
+func (n *entryTTLs) SetCareerTTL(ttl time.Duration) {
+	n.career = ttl
+}
+
+// statsTTL returns how long stats are kept, ttl unless they are private
+func (n *entryTTLs) statsTTL(ttl time.Duration, private bool) time.Duration {
+	if private && n.private > 0 {
+		return n.private
+	}
+	return ttl
+}
@@ -0,0 +1,255 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Domekologe/ow-api/ovrstat"
+)
+
+// TieredStore keeps recently read stats decoded in memory (L1) in front of
+// Redis (L2). Writes from other processes, e.g. the scraper, drop the L1 copy
+// through Redis pub/sub; if a message is lost, the short L1 TTL bounds how
+// long a stale copy can be served.
+//
+// Stats returned from L1 are shared between callers. Their top level fields
+// may be changed, nested maps and pointers must be treated as read-only.
+type TieredStore struct {
+	l2 *RedisCache
+
+	mu  sync.Mutex
+	l1  *lru
+	ttl time.Duration
+	// gen is bumped by every invalidation, which happen after the write to
+	// L2. A read only fills L1 if none happened while it was reading L2.
+	gen uint64
+
+	l1Hits, l2Hits, misses int64
+
+	unsubscribe func() error
+}
+
+// NewTieredStore puts an L1 of at most maxEntries entries, each kept for ttl,
+// in front of l2
+func NewTieredStore(l2 *RedisCache, maxEntries int, ttl time.Duration) *TieredStore {
+	t := &TieredStore{
+		l2:  l2,
+		l1:  newLRU(maxEntries),
+		ttl: ttl,
+	}
+	t.unsubscribe = l2.subscribeInvalidations(t.invalidate)
+	return t
+}
+
+// invalidate drops keys from L1
+func (t *TieredStore) invalidate(keys []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.gen++
+	for _, key := range keys {
+		if key == invalidateAll {
+			t.l1.flush()
+			return
+		}
+		t.l1.remove(key)
+	}
+}
+
+// fromL1 returns the L1 entry under key, or the generation to pass to toL1
+// after reading L2
+func (t *TieredStore) fromL1(key string) (item *lruItem, gen uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if item, ok := t.l1.get(key); ok {
+		atomic.AddInt64(&t.l1Hits, 1)
+		return item, 0
+	}
+	return nil, t.gen
+}
+
+// toL1 stores a value read from L2 unless it was invalidated meanwhile
+func (t *TieredStore) toL1(key string, v interface{}, fetchedAt time.Time, gen uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.gen != gen {
+		return
+	}
+	t.l1.set(&lruItem{key: key, value: v, fetchedAt: fetchedAt, expiresAt: time.Now().Add(t.ttl)})
+}
+
+// countL2 records the outcome of a read that missed L1
+func (t *TieredStore) countL2(found bool) {
+	if found {
+		atomic.AddInt64(&t.l2Hits, 1)
+	} else {
+		atomic.AddInt64(&t.misses, 1)
+	}
+}
+
+// Get retrieves cached player stats
+func (t *TieredStore) Get(platform, tag string) (*ovrstat.PlayerStats, error) {
+	stats, _, err := t.GetEntry(platform, tag)
+	return stats, err
+}
+
+// GetEntry retrieves cached player stats and when they were fetched
+func (t *TieredStore) GetEntry(platform, tag string) (*ovrstat.PlayerStats, time.Time, error) {
+	key := statsKey(platform, tag)
+	item, gen := t.fromL1(key)
+	if item != nil {
+		stats := *item.value.(*ovrstat.PlayerStats)
+		return &stats, item.fetchedAt, nil
+	}
+
+	stats, fetchedAt, err := t.l2.GetEntry(platform, tag)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	t.countL2(stats != nil)
+	if stats != nil {
+		shared := *stats
+		t.toL1(key, &shared, fetchedAt, gen)
+	}
+	return stats, fetchedAt, nil
+}
+
+// Set stores player stats in both tiers
+func (t *TieredStore) Set(platform, tag string, stats *ovrstat.PlayerStats) error {
+	err := t.l2.Set(platform, tag, stats)
+	t.invalidate([]string{statsKey(platform, tag)})
+	return err
+}
+
+// GetProfile retrieves cached player profile stats
+func (t *TieredStore) GetProfile(platform, tag string) (*ovrstat.PlayerStatsProfile, error) {
+	stats, _, err := t.GetProfileEntry(platform, tag)
+	return stats, err
+}
+
+// GetProfileEntry retrieves cached player profile stats and when they were
+// fetched
+func (t *TieredStore) GetProfileEntry(platform, tag string) (*ovrstat.PlayerStatsProfile, time.Time, error) {
+	key := profileKey(platform, tag)
+	item, gen := t.fromL1(key)
+	if item != nil {
+		stats := *item.value.(*ovrstat.PlayerStatsProfile)
+		return &stats, item.fetchedAt, nil
+	}
+
+	stats, fetchedAt, err := t.l2.GetProfileEntry(platform, tag)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	t.countL2(stats != nil)
+	if stats != nil {
+		shared := *stats
+		t.toL1(key, &shared, fetchedAt, gen)
+	}
+	return stats, fetchedAt, nil
+}
+
+// SetProfile stores player profile stats in both tiers
+func (t *TieredStore) SetProfile(platform, tag string, stats *ovrstat.PlayerStatsProfile) error {
+	err := t.l2.SetProfile(platform, tag, stats)
+	t.invalidate([]string{profileKey(platform, tag)})
+	return err
+}
+
+// SetNotFound remembers that a player doesn't exist and drops their stats
+// from both tiers
+func (t *TieredStore) SetNotFound(platform, tag string) error {
+	err := t.l2.SetNotFound(platform, tag)
+	key := statsKey(platform, tag)
+	t.invalidate([]string{key, profileKey(platform, tag)})
+	return err
+}
+
+// Extend keeps the stats of a player in Redis for at least ttl
+func (t *TieredStore) Extend(platform, tag string, ttl time.Duration) error {
+	return t.l2.Extend(platform, tag, ttl)
+}
+
+// Negative returns the negative entry of a player from Redis, if any
+func (t *TieredStore) Negative(platform, tag string) (string, time.Time, error) {
+	return t.l2.Negative(platform, tag)
+}
+
+// Inspect describes all entries of a player in Redis
+func (t *TieredStore) Inspect(platform, tag string) (*PlayerEntries, error) {
+	return t.l2.Inspect(platform, tag)
+}
+
+// CareerID returns the career ID tag resolved to last from Redis
+func (t *TieredStore) CareerID(tag string) (string, error) {
+	return t.l2.CareerID(tag)
+}
+
+// SetCareerID remembers the career ID tag resolves to in Redis
+func (t *TieredStore) SetCareerID(tag, careerID string) error {
+	return t.l2.SetCareerID(tag, careerID)
+}
+
+// Career returns what is known about a career page from Redis
+func (t *TieredStore) Career(careerID string) (*Career, error) {
+	return t.l2.Career(careerID)
+}
+
+// SetCareer stores what is known about a career page in Redis
+func (t *TieredStore) SetCareer(c *Career) error {
+	return t.l2.SetCareer(c)
+}
+
+// Delete removes all cached entries of a player
+func (t *TieredStore) Delete(platform, tag string) error {
+	err := t.l2.Delete(platform, tag)
+	key := statsKey(platform, tag)
+	t.invalidate([]string{key, profileKey(platform, tag)})
+	return err
+}
+
+// ScanKeys calls fn with the keys in Redis matching pattern
+func (t *TieredStore) ScanKeys(pattern string, fn func(key string) error) error {
+	return t.l2.ScanKeys(pattern, fn)
+}
+
+// Flush clears both tiers
+func (t *TieredStore) Flush() error {
+	err := t.l2.Flush()
+	t.invalidate([]string{invalidateAll})
+	return err
+}
+
+// Stats returns the size of Redis and the hit counters of both tiers
+func (t *TieredStore) Stats() (StoreStats, error) {
+	st, err := t.l2.Stats()
+	if err != nil {
+		return st, err
+	}
+
+	t.mu.Lock()
+	st.L1Entries = int64(t.l1.len())
+	st.Evictions = t.l1.evictions
+	t.mu.Unlock()
+
+	st.Backend = "redis+l1"
+	st.MaxEntries = t.l1.maxEntries
+	st.L1Hits = atomic.LoadInt64(&t.l1Hits)
+	st.L2Hits = atomic.LoadInt64(&t.l2Hits)
+	st.Misses = atomic.LoadInt64(&t.misses)
+	return st, nil
+}
+
+// TTL returns how long entries are kept in Redis
+func (t *TieredStore) TTL() time.Duration {
+	return t.l2.TTL()
+}
+
+// Close ends the invalidation subscription and closes Redis
+func (t *TieredStore) Close() error {
+	t.unsubscribe()
+	return t.l2.Close()
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math/rand/v2"
 	"os"
 	"os/signal"
-	"strings"
+	"slices"
 	"syscall"
 	"time"
 
@@ -13,6 +17,47 @@ import (
 	"github.com/Domekologe/ow-api/ovrstat"
 )
 
+// client performs all upstream lookups of the scraper
+var client = ovrstat.NewClient()
+
+// negativeInterval is how often not-found players and private profiles are
+// refreshed
+var negativeInterval time.Duration
+
+// queue holds the players waiting to be refreshed, runs records the runs
+// over them
+var (
+	queue cache.RefreshQueue
+	runs  *cache.ScraperRuns
+)
+
+// pace spaces the refreshes of all workers, each waits up to jitter on top
+var (
+	pace   *ovrstat.Limiter
+	jitter time.Duration
+)
+
+// runDeadline is how long a run may take, lastScheduled is the ID of the
+// latest scheduled run
+var (
+	runDeadline   time.Duration
+	lastScheduled string
+)
+
+// popularity holds the lookups the API recorded. Scheduled runs leave out
+// players not looked up for idleAfter.
+var (
+	popularity *cache.Popularity
+	idleAfter  time.Duration
+)
+
+// refreshAhead is how long before they expire entries are refreshed first,
+// extendOnFailure how long they are kept at least when that fails
+var (
+	refreshAhead    time.Duration
+	extendOnFailure time.Duration
+)
+
 func main() {
 	log.Println("Starting Overwatch Stats Scraper...")
 
@@ -29,21 +74,64 @@ func main() {
 	}
 
 	// Connect to Redis
-	redisCache, err := cache.NewRedisCache(
-		cfg.Redis.Host,
-		cfg.Redis.Port,
-		cfg.Redis.Password,
-		cfg.Redis.DB,
-		cfg.GetCacheTTL(),
-	)
+	redisCache, err := cfg.RedisCache()
 	if err != nil {
+		log.Fatalf("Invalid Redis configuration: %v", err)
+	}
+	if err := redisCache.Ping(); err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
 	defer redisCache.Close()
+	redisCache.SetSource("scraper")
+	negativeInterval = cfg.GetScraperNegativeInterval()
+
+	log.Printf("Connected to Redis at %s", cfg.RedisOptions())
 
-	log.Printf("Connected to Redis at %s:%d", cfg.Redis.Host, cfg.Redis.Port)
+	// The scraper isn't in a hurry, it waits for upstream budget instead of
+	// giving up. The shared budget leaves room for the API's live lookups.
+	limiterCfg := cfg.LimiterConfig()
+	limiterCfg.MaxWait = 0
+	if cfg.Upstream.Shared && limiterCfg.Rate > 0 {
+		limiterCfg.Shared = redisCache.RateBudget(limiterCfg.Rate)
+	}
+	client = ovrstat.NewClient(ovrstat.WithLimiter(ovrstat.NewLimiter(limiterCfg)))
 	log.Printf("Scraper interval: %s", cfg.Scraper.Interval)
 
+	// Players are refreshed by workers from the refresh queue. With the
+	// redis backend it also holds the on-demand refreshes of the API, which
+	// go ahead of the periodic ones.
+	queue = cfg.RefreshQueue(redisCache)
+	runs = redisCache.ScraperRuns()
+	pace = ovrstat.NewLimiter(ovrstat.LimiterConfig{Rate: cfg.Scraper.Rate})
+	jitter = cfg.GetScraperJitter()
+	runDeadline = cfg.GetScraperRunDeadline()
+	popularity = redisCache.Popularity()
+	idleAfter = cfg.GetScraperIdleAfter()
+	refreshAhead = cfg.GetScraperRefreshAhead()
+	extendOnFailure = cfg.GetScraperExtendOnFailure()
+	workers := max(cfg.Scraper.Workers, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	workersDone := make(chan struct{})
+	go func() {
+		cache.RunRefreshWorkers(ctx, queue, cache.PriorityPeriodic, workers, func(ctx context.Context, job cache.RefreshJob) {
+			refreshJob(ctx, redisCache, job)
+		})
+		close(workersDone)
+	}()
+	log.Printf("Refresh queue: %s backend, %d workers, %g players/s, run deadline %s",
+		cfg.Refresh.Backend, workers, cfg.Scraper.Rate, runDeadline)
+
+	// Admins trigger runs through Redis
+	triggers := make(chan *cache.ScraperRun, 16)
+	unsubscribe := runs.Subscribe(func(run *cache.ScraperRun) {
+		select {
+		case triggers <- run:
+		default:
+			runs.Fail(run.ID, errors.New("too many runs triggered at once"))
+		}
+	})
+	defer unsubscribe()
+
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -55,106 +143,372 @@ func main() {
 
 	// Run initial scrape
 	log.Println("Running initial scrape...")
-	scrapeAll(redisCache)
+	scrapeScheduled(redisCache)
 
 	// Main loop
 	for {
 		select {
 		case <-ticker.C:
 			log.Println("Starting scheduled scrape...")
-			scrapeAll(redisCache)
+			scrapeScheduled(redisCache)
+		case run := <-triggers:
+			log.Printf("Starting triggered scrape of %s...", run.Target)
+			scrapeRun(redisCache, run)
 		case sig := <-sigChan:
 			log.Printf("Received signal %v, shutting down gracefully...", sig)
+			cancel()
+			<-workersDone
 			return
 		}
 	}
 }
 
-// scrapeAll fetches and updates all cached players
-func scrapeAll(cache *cache.RedisCache) {
-	startTime := time.Now()
+// player is a cached player, identified across their complete and profile
+// entries
+type player struct {
+	id ovrstat.PlayerID
+
+	// legacyTags are tags the player is cached under besides id.Key(), from
+	// before keys were canonical. They are dropped once the player is cached
+	// under id.Key().
+	legacyTags []string
+}
+
+// scrapeScheduled starts the periodic run over all cached players, unless
+// the previous one is still running
+func scrapeScheduled(rc *cache.RedisCache) {
+	if lastScheduled != "" && !runs.Expired(lastScheduled) {
+		prev, err := runs.Get(lastScheduled)
+		if err == nil && prev != nil && prev.Status == cache.RunRunning {
+			log.Printf("Run %s is still running (%d/%d players), skipping this one",
+				prev.ID, prev.Succeeded+prev.Failed+prev.Skipped+prev.Dropped+prev.Expired, prev.Total)
+			return
+		}
+	}
+
+	run, err := runs.Create(cache.TriggerSchedule, cache.RunTarget{})
+	if err != nil {
+		log.Printf("Failed to start scrape: %v", err)
+		return
+	}
+	lastScheduled = run.ID
+	scrapeRun(rc, run)
+}
+
+// runPatterns returns the key patterns of the players a run is about
+func runPatterns(target cache.RunTarget) []string {
+	switch {
+	case target.Pattern != "":
+		return []string{target.Pattern}
+	case target.Platform != "":
+		return cache.PlatformKeyPatterns(target.Platform)
+	}
+	return []string{cache.StatsKeyPattern, cache.NegativeKeyPattern(cache.NegativeNotFound)}
+}
 
-	// Get all cached player keys (both complete and profile)
-	keys, err := cache.GetKeys("ow:stats:*")
+// scrapeRun queues the players of a run for a refresh. The workers report
+// their outcome to the run.
+func scrapeRun(rc *cache.RedisCache, run *cache.ScraperRun) {
+	players, keys, err := runPlayers(rc, run.Target)
 	if err != nil {
 		log.Printf("Failed to get cached keys: %v", err)
+		runs.Fail(run.ID, err)
+		return
+	}
+	if run.Trigger == cache.TriggerSchedule {
+		players = schedule(rc, players)
+	}
+	if err := runs.Start(run.ID, len(players), runDeadline); err != nil {
+		log.Printf("Failed to start run %s: %v", run.ID, err)
 		return
 	}
 
-	if len(keys) == 0 {
+	if keys == 0 {
 		log.Println("No cached players found")
-		return
+	} else {
+		log.Printf("Found %d cached entries (%d players) to update", keys, len(players))
 	}
 
-	log.Printf("Found %d cached entries to update", len(keys))
+	queued := 0
+	skipped := 0
+	dropped := 0
 
-	successful := 0
-	errors := 0
+	for _, p := range players {
+		dropLegacyTags(rc, p)
 
-	for i, key := range keys {
-		// Parse key format: ow:stats:platform:tag or ow:stats:platform:tag:profile
-		parts := strings.Split(key, ":")
-		if len(parts) < 4 {
-			log.Printf("Invalid key format: %s", key)
-			continue
-		}
-
-		platform := parts[2]
-		tag := parts[3]
-		isProfile := len(parts) == 5 && parts[4] == "profile"
-
-		log.Printf("[%d/%d] Updating %s/%s%s...", i+1, len(keys), platform, tag,
-			func() string {
-				if isProfile {
-					return " (profile)"
-				}
-				return ""
-			}())
-
-		var updateErr error
-		if isProfile {
-			// Update profile stats
-			stats, err := ovrstat.ProfileStats(platform, tag)
-			if err != nil {
-				log.Printf("  ✗ Failed: %v", err)
-				errors++
-				updateErr = err
-			} else {
-				if err := cache.SetProfile(platform, tag, stats); err != nil {
-					log.Printf("  ✗ Cache update failed: %v", err)
-					errors++
-					updateErr = err
-				} else {
-					log.Printf("  ✓ Updated successfully")
-					successful++
-				}
+		// Not-found players and private profiles rarely change, scheduled
+		// runs only refresh them every negativeInterval
+		if run.Trigger == cache.TriggerSchedule {
+			kind, since, err := rc.Negative(p.id.Platform, p.id.Key())
+			if err == nil && kind != "" && time.Since(since) < negativeInterval {
+				skipped++
+				continue
+			}
+		}
+
+		job := cache.RefreshJob{Platform: p.id.Platform, Tag: p.id.Key(), Priority: cache.PriorityPeriodic, Run: run.ID}
+		ok, victim, err := queue.Push(job)
+		if victim != nil {
+			runs.Done(victim.Run, cache.ErrQueueFull)
+		}
+		switch {
+		case err != nil:
+			if !errors.Is(err, cache.ErrQueueFull) {
+				log.Printf("Failed to queue %s: %v", p.id, err)
 			}
-		} else {
-			// Update complete stats
-			stats, err := ovrstat.Stats(platform, tag)
-			if err != nil {
-				log.Printf("  ✗ Failed: %v", err)
-				errors++
-				updateErr = err
-			} else {
-				if err := cache.Set(platform, tag, stats); err != nil {
-					log.Printf("  ✗ Cache update failed: %v", err)
-					errors++
-					updateErr = err
-				} else {
-					log.Printf("  ✓ Updated successfully")
-					successful++
-				}
+			runs.Done(run.ID, err)
+			dropped++
+		case ok:
+			queued++
+		default:
+			// Already queued, e.g. by the API or an earlier run
+			skipped++
+		}
+	}
+
+	if err := runs.Skip(run.ID, skipped); err != nil {
+		log.Printf("Failed to update run %s: %v", run.ID, err)
+	}
+	log.Printf("Run %s: queued %d players, %d skipped, %d dropped", run.ID, queued, skipped, dropped)
+}
+
+// schedule orders the players of a scheduled run and leaves out idle
+// players, see cache.Schedule. Players the API hasn't looked up since lookups
+// are recorded are tracked from now on. The queue refreshes them in this
+// order, so the ones left at the deadline matter least.
+func schedule(rc *cache.RedisCache, players []*player) []*player {
+	keys := make([]cache.PlayerKey, len(players))
+	cached := make(map[cache.PlayerKey]bool, len(players))
+	for i, p := range players {
+		keys[i] = cache.PlayerKey{Platform: p.id.Platform, Tag: p.id.Key()}
+		cached[keys[i]] = true
+	}
+
+	// Forget idle players once their entries are gone. Cached ones stay
+	// tracked, so they aren't tracked anew below.
+	if idleAfter > 0 {
+		n, err := popularity.Prune(idleAfter, func(platform, tag string) bool {
+			return cached[cache.PlayerKey{Platform: platform, Tag: tag}]
+		})
+		if err != nil {
+			log.Printf("Failed to prune idle players: %v", err)
+		} else if n > 0 {
+			log.Printf("Forgot %d players not looked up for %s", n, idleAfter)
+		}
+	}
+
+	accesses, err := popularity.Accesses(keys)
+	if err != nil {
+		log.Printf("Failed to get player lookups, refreshing all players: %v", err)
+		return players
+	}
+	ages, err := rc.EntryAges(keys)
+	if err != nil {
+		log.Printf("Failed to get cache TTLs, refreshing all players: %v", err)
+		return players
+	}
+
+	var untracked []cache.PlayerKey
+	candidates := make([]cache.Candidate, len(players))
+	for i := range players {
+		candidates[i] = cache.Candidate{Access: accesses[i], Entry: ages[i]}
+		if accesses[i].Last.IsZero() {
+			untracked = append(untracked, keys[i])
+		}
+	}
+	if err := popularity.Seed(untracked); err != nil {
+		log.Printf("Failed to track players: %v", err)
+	}
+	// Halve the counts for the next run, so popularity follows recent
+	// lookups
+	if err := popularity.Decay(); err != nil {
+		log.Printf("Failed to decay player lookups: %v", err)
+	}
+
+	order := cache.Schedule(candidates, idleAfter, refreshAhead, time.Now())
+	scheduled := make([]*player, len(order))
+	expiring := 0
+	for i, j := range order {
+		scheduled[i] = players[j]
+		if e := ages[j].ExpiresIn; e > 0 && e < refreshAhead {
+			expiring++
+		}
+	}
+
+	if idle := len(players) - len(scheduled); idle > 0 {
+		log.Printf("Leaving out %d players not looked up for %s", idle, idleAfter)
+	}
+	if expiring > 0 {
+		log.Printf("Refreshing %d players expiring within %s first", expiring, refreshAhead)
+	}
+	return scheduled
+}
+
+// runPlayers returns the players a run is about, and how many keys they were
+// found under. Players that were not found are included, in case they exist
+// now.
+func runPlayers(rc *cache.RedisCache, target cache.RunTarget) ([]*player, int, error) {
+	if target.Tag != "" {
+		id, err := ovrstat.ParsePlayerID(target.Platform, target.Tag)
+		if err != nil {
+			return nil, 0, err
+		}
+		return []*player{{id: id}}, 1, nil
+	}
+
+	// Group all cached entries (both complete and profile) by player, one
+	// career page refreshes both of them
+	var players []*player
+	seen := make(map[string]*player)
+	keys := 0
+	collect := func(key string) error {
+		keys++
+		pk, ok := cache.ParseKey(key)
+		if !ok {
+			log.Printf("Invalid key format: %s", key)
+			return nil
+		}
+		id, err := ovrstat.ParsePlayerID(pk.Platform, pk.Tag)
+		if err != nil {
+			log.Printf("Invalid player in key %s: %v", key, err)
+			return nil
+		}
+
+		seenKey := id.Platform + ":" + id.Key()
+		p := seen[seenKey]
+		if p == nil {
+			p = &player{id: id}
+			seen[seenKey] = p
+			players = append(players, p)
+		}
+		if pk.Tag != id.Key() && !slices.Contains(p.legacyTags, pk.Tag) {
+			p.legacyTags = append(p.legacyTags, pk.Tag)
+		}
+		return nil
+	}
+	for _, pattern := range runPatterns(target) {
+		if err := rc.ScanKeys(pattern, collect); err != nil {
+			return nil, 0, err
+		}
+	}
+	return players, keys, nil
+}
+
+// dropLegacyTags removes the entries of a player under legacy tags once they
+// are cached under their canonical one
+func dropLegacyTags(rc *cache.RedisCache, p *player) {
+	if len(p.legacyTags) == 0 {
+		return
+	}
+	entries, err := rc.Inspect(p.id.Platform, p.id.Key())
+	if err != nil || entries.Empty() {
+		return
+	}
+	for _, tag := range p.legacyTags {
+		if err := rc.Delete(p.id.Platform, tag); err != nil {
+			log.Printf("Failed to drop legacy key %s: %v", tag, err)
+		}
+	}
+}
+
+// refreshJob refreshes the player of a job from the refresh queue, once the
+// pace of the workers allows it
+func refreshJob(ctx context.Context, rc *cache.RedisCache, job cache.RefreshJob) {
+	id, err := ovrstat.ParsePlayerID(job.Platform, job.Tag)
+	if err != nil {
+		log.Printf("Invalid player in refresh job %s:%s: %v", job.Platform, job.Tag, err)
+		runs.Done(job.Run, err)
+		return
+	}
+	// Jobs left at the deadline of their run are given up without waiting,
+	// so they drain before the next run
+	if runs.Expired(job.Run) {
+		runs.Done(job.Run, cache.ErrRunExpired)
+		return
+	}
+	if err := wait(ctx); err != nil {
+		// Shutting down. The job is counted as failed, so its run still
+		// finishes.
+		if err := runs.Done(job.Run, err); err != nil {
+			log.Printf("Failed to update run %s: %v", job.Run, err)
+		}
+		return
+	}
+
+	err = updatePlayer(ctx, rc, id)
+	if err := runs.Done(job.Run, err); err != nil {
+		log.Printf("Failed to update run %s: %v", job.Run, err)
+	}
+	if err != nil {
+		log.Printf("✗ Failed to update %s (%s): %v", id, job.Priority, err)
+		// Keep the last good stats, e.g. through a Blizzard outage. Players
+		// that no longer exist are dropped by updatePlayer.
+		if extendOnFailure > 0 && !errors.Is(err, ovrstat.ErrPlayerNotFound) {
+			if err := rc.Extend(id.Platform, id.Key(), extendOnFailure); err != nil {
+				log.Printf("Failed to keep the entries of %s: %v", id, err)
 			}
 		}
+		return
+	}
+	log.Printf("✓ Updated %s (%s)", id, job.Priority)
+}
+
+// wait waits for the pace of the workers and a random jitter, whether the
+// previous refreshes succeeded or not
+func wait(ctx context.Context) error {
+	release, err := pace.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	release()
 
-		// Small delay to avoid overwhelming the server
-		if updateErr == nil {
-			time.Sleep(2 * time.Second)
+	if jitter <= 0 {
+		return nil
+	}
+	t := time.NewTimer(rand.N(jitter))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// updatePlayer fetches the career page of a player once and refreshes both
+// of their cache entries from it
+func updatePlayer(ctx context.Context, rc *cache.RedisCache, id ovrstat.PlayerID) error {
+	page, err := client.CareerPageContext(ctx, id.Tag())
+	if err != nil {
+		if errors.Is(err, ovrstat.ErrPlayerNotFound) {
+			if err := rc.SetNotFound(id.Platform, id.Key()); err != nil {
+				return fmt.Errorf("cache update failed: %w", err)
+			}
 		}
+		return err
 	}
 
-	duration := time.Since(startTime)
-	log.Printf("Scrape completed in %v: %d successful, %d errors",
-		duration.Round(time.Second), successful, errors)
+	stats, err := page.Stats(id.Platform)
+	if err != nil {
+		return err
+	}
+	profile, err := page.ProfileStats(id.Platform)
+	if err != nil {
+		return err
+	}
+
+	if err := rc.Set(id.Platform, id.Key(), stats); err != nil {
+		return fmt.Errorf("cache update failed: %w", err)
+	}
+	if err := rc.SetProfile(id.Platform, id.Key(), profile); err != nil {
+		return fmt.Errorf("profile cache update failed: %w", err)
+	}
+	// The scraper always resolves the tag, which keeps the career IDs the
+	// API relies on current
+	if page.CareerID != "" {
+		if err := rc.SetCareerID(id.Key(), page.CareerID); err != nil {
+			return fmt.Errorf("career ID cache update failed: %w", err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,369 @@
+package ovrstat
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pkg/errors"
+)
+
+// CareerPage is a downloaded and pre-parsed career page of a player. One page
+// holds the data of every platform, so both the complete and the profile
+// stats of a player can be produced from a single fetch.
+type CareerPage struct {
+	// CareerID is the name|hash ID the BattleTag resolved to
+	CareerID string
+
+	// Player holds the metadata returned by the account search
+	Player *Player
+
+	// Private is set when the profile is not public, in which case no page
+	// is downloaded and all stats are empty
+	Private bool
+
+	doc       *goquery.Document
+	platforms map[string]Platform
+	namecard  namecard
+}
+
+// namecard holds the namecard of the masthead and, if the unlocks API knew
+// it, its resolved title and image
+type namecard struct {
+	ID     string
+	Unlock *UnlockData
+}
+
+// CareerPageContext resolves a player and downloads their career page once.
+// Upstream requests are aborted once ctx is done.
+func (c *Client) CareerPageContext(ctx context.Context, tag string) (*CareerPage, error) {
+	// Existenz über Career-Redirect prüfen
+	careerID, err := c.resolveCareerID(ctx, tag)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	c.debugf("Resolved CareerID: %s", careerID)
+	return c.CareerPageByIDContext(ctx, careerID, tag)
+}
+
+// CareerPageByIDContext downloads the career page of a player whose career ID
+// is already known, skipping the resolution of their BattleTag. tag is only
+// used to look up search metadata and may be empty, the page then tells
+// whether the profile is private.
+func (c *Client) CareerPageByIDContext(ctx context.Context, careerID, tag string) (*CareerPage, error) {
+	page := &CareerPage{
+		CareerID: careerID,
+		Player:   &Player{IsPublic: true},
+	}
+	if tag != "" {
+		page.Player = c.searchPlayer(ctx, tag)
+	}
+
+	if !page.Player.IsPublic {
+		page.Private = true
+		return page, nil
+	}
+
+	// Create the profile url for scraping
+	profileUrl := c.careerURL() + "/" + careerID + "/"
+
+	c.debugf("Profile URL: %s", profileUrl)
+
+	// Perform the stats request and decode the response
+	req, err := c.newRequest(ctx, profileUrl)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to build profile request")
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(requestErr("career", err), "Failed to retrieve profile")
+	}
+	defer res.Body.Close()
+
+	if err := statusErr("career", res.StatusCode); err != nil {
+		return nil, err
+	}
+
+	// Parses the stats request into a goquery document
+	pd, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(parseErr("career", err), "Failed to create goquery document")
+	}
+
+	if err := page.parse(pd); err != nil {
+		return nil, err
+	}
+
+	// Hole die Info aus Blizzard Unlocks API
+	if page.namecard.ID != "" {
+		if unlockInfo, err := c.UnlockInfoContext(ctx, page.namecard.ID); err == nil {
+			page.namecard.Unlock = unlockInfo
+		}
+	}
+
+	return page, nil
+}
+
+// parse attaches a downloaded career page. It does no network requests, so
+// captured pages can be parsed offline.
+func (p *CareerPage) parse(pd *goquery.Document) error {
+	// Checks if profile not found, site still returns 200 in this case
+	if pd.Find("[slot=heading]").First().Text() == "Page Not Found" {
+		return ErrPlayerNotFound
+	}
+
+	p.doc = pd
+
+	// The search does not always know a profile is private, the page does
+	if pd.Find(".Profile-private---msg").Length() > 0 {
+		p.Private = true
+		return nil
+	}
+
+	// Every career page has a masthead, without it nothing else can be
+	// trusted to be where the parser expects it
+	if p.masthead().Length() == 0 {
+		return &UpstreamError{Op: "career", Kind: ErrProfileLayoutChanged,
+			Err: errors.New("no .Profile-masthead on the career page")}
+	}
+
+	p.platforms = parsePlatforms(pd)
+
+	// Try to get Namecard
+	if namecardAttr, exists := p.masthead().Attr("namecard-id"); exists {
+		p.namecard.ID = namecardAttr
+	}
+
+	return nil
+}
+
+// parsePlatforms finds the views of every platform the player has stats for
+func parsePlatforms(pd *goquery.Document) map[string]Platform {
+	platforms := make(map[string]Platform)
+
+	pd.Find(".Profile-player--filters .Profile-player--filter").Each(func(i int, sel *goquery.Selection) {
+		id, _ := sel.Attr("id")
+
+		idMatch := filterRegexp.FindStringSubmatch(id)
+		if idMatch == nil {
+			return
+		}
+		id = idMatch[1]
+
+		viewID := "." + id + "-view"
+
+		// Using combined classes (.class.class2) we can filter out our views based on platform
+		rankWrapper := pd.Find(".Profile-playerSummary--rankWrapper" + viewID)
+
+		view := pd.Find(".Profile-view" + viewID)
+
+		if view.Length() == 0 {
+			return
+		}
+
+		platforms[id] = Platform{
+			Name:        sel.Text(),
+			RankWrapper: rankWrapper,
+			ProfileView: view,
+		}
+	})
+
+	return platforms
+}
+
+// platformView maps a public platform key (pc, console) to the view on the page
+func (p *CareerPage) platformView(platformKey string) (Platform, error) {
+	// Do platform key mapping
+	switch platformKey {
+	case PlatformPC:
+		platformKey = "mouseKeyboard"
+	case PlatformConsole:
+		platformKey = "controller"
+	}
+
+	platform, exists := p.platforms[platformKey]
+	if !exists {
+		return Platform{}, ErrInvalidPlatform
+	}
+	return platform, nil
+}
+
+func (p *CareerPage) masthead() *goquery.Selection {
+	return p.doc.Find(".Profile-masthead").First()
+}
+
+func (p *CareerPage) name() string {
+	return p.doc.Find(".Profile-player--name").Text()
+}
+
+// Name returns the name shown on the career page, without the number of the
+// BattleTag. It is empty when no page was downloaded.
+func (p *CareerPage) Name() string {
+	if p.doc == nil {
+		return ""
+	}
+	return strings.TrimSpace(p.name())
+}
+
+func (p *CareerPage) competitiveSeason() *int {
+	seasonAttr, _ := p.doc.Find("[data-latestherostatrankseasonow2]").Attr("data-latestherostatrankseasonow2")
+	if seasonAttr == "" {
+		return nil
+	}
+	season, err := strconv.Atoi(seasonAttr)
+	if err != nil {
+		return nil
+	}
+	return &season
+}
+
+// applyNamecard fills the namecard fields, preferring the unlocks API over
+// the namecard returned by the search
+func (p *CareerPage) applyNamecard(id, title, image *string) {
+	*image = p.Player.Namecard
+	if p.namecard.ID == "" {
+		return
+	}
+	*id = p.namecard.ID
+	if p.namecard.Unlock != nil {
+		*title = p.namecard.Unlock.Name
+		*image = p.namecard.Unlock.Icon
+	}
+}
+
+// Stats returns the complete stats of the player on the passed platform
+func (p *CareerPage) Stats(platformKey string) (*PlayerStats, error) {
+	ps := PlayerStats{CareerID: p.CareerID}
+
+	if p.Private {
+		ps.Private = true
+		return &ps, nil
+	}
+
+	platform, err := p.platformView(platformKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ps.Name = p.name()
+	p.applyNamecard(&ps.NamecardID, &ps.NamecardTitle, &ps.NamecardImage)
+
+	// Scrapes all stats for the passed user and sets struct member data
+	parseGeneralInfo(platform, p.masthead(), &ps)
+
+	parseDetailedStats(platform, ".quickPlay-view", &ps.QuickPlayStats.StatsCollection)
+	parseDetailedStats(platform, ".competitive-view", &ps.CompetitiveStats.StatsCollection)
+
+	ps.CompetitiveStats.Season = p.competitiveSeason()
+
+	addGameStats(&ps, &ps.QuickPlayStats.StatsCollection)
+	addGameStats(&ps, &ps.CompetitiveStats.StatsCollection)
+
+	return &ps, nil
+}
+
+// ProfileStats returns the profile summary of the player on the passed platform
+func (p *CareerPage) ProfileStats(platformKey string) (*PlayerStatsProfile, error) {
+	ps := PlayerStatsProfile{CareerID: p.CareerID}
+
+	if p.Private {
+		ps.Private = true
+		return &ps, nil
+	}
+
+	platform, err := p.platformView(platformKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ps.Name = p.name()
+	p.applyNamecard(&ps.NamecardID, &ps.NamecardTitle, &ps.NamecardImage)
+
+	// Scrapes all stats for the passed user and sets struct member data
+	parseGeneralInfoProfile(platform, p.masthead(), &ps)
+
+	careerStats := parseCareerStats(platform.ProfileView.Find(".stats.competitive-view"))
+
+	if heroStats, ok := careerStats["allHeroes"]; ok {
+		if gamesPlayed, ok := heroStats.Game["gamesPlayed"].(int); ok {
+			ps.CompetitiveStats.GamesPlayed = gamesPlayed
+		}
+		if gamesWon, ok := heroStats.Game["gamesWon"].(int); ok {
+			ps.CompetitiveStats.GamesWon = gamesWon
+		}
+		if gamesLost, ok := heroStats.Game["gamesLost"].(int); ok {
+			ps.CompetitiveStats.GamesLost = gamesLost
+		}
+		if timePlayed, ok := heroStats.Game["timePlayed"].(string); ok {
+			ps.CompetitiveStats.TimePlayed = timePlayed
+		}
+	}
+	careerStatsQP := parseCareerStats(platform.ProfileView.Find(".stats.quickPlay-view"))
+	ps.CompetitiveStats.Season = p.competitiveSeason()
+
+	if heroStats, ok := careerStatsQP["allHeroes"]; ok {
+		if gamesPlayed, ok := heroStats.Game["gamesPlayed"].(int); ok {
+			ps.QuickplayStats.GamesPlayed = gamesPlayed
+		}
+		if gamesWon, ok := heroStats.Game["gamesWon"].(int); ok {
+			ps.QuickplayStats.GamesWon = gamesWon
+		}
+		if gamesLost, ok := heroStats.Game["gamesLost"].(int); ok {
+			ps.QuickplayStats.GamesLost = gamesLost
+		}
+		if timePlayed, ok := heroStats.Game["timePlayed"].(string); ok {
+			ps.QuickplayStats.TimePlayed = timePlayed
+		}
+	}
+
+	mostPlayedHero := platform.ProfileView.
+		Find(".Profile-heroSummary--view.competitive-view").
+		Find(".Profile-progressBar-title").
+		First().
+		Text()
+
+	ps.CompetitiveStats.MostPlayedHero = strings.TrimSpace(mostPlayedHero)
+	ps.CompetitiveStats.MostPlayedHeroTimePlayed = platform.ProfileView.
+		Find(".Profile-heroSummary--view.competitive-view").
+		Find(".Profile-progressBar-description").
+		First().
+		Text()
+
+	if heroStats, ok := careerStats[cleanJSONKey(ps.CompetitiveStats.MostPlayedHero)]; ok {
+		if val, ok := heroStats.Game["gamesPlayed"]; ok {
+			if i, ok := val.(int); ok {
+				ps.CompetitiveStats.MostPlayedHeroGamesPlayed = i
+			}
+		}
+		ps.CompetitiveStats.MostPlayedHeroWinPercentage = statInt(heroStats.Game, "winPercentage")
+	}
+
+	mostPlayedHeroQP := platform.ProfileView.
+		Find(".Profile-heroSummary--view.quickPlay-view").
+		Find(".Profile-progressBar-title").
+		First().
+		Text()
+
+	ps.QuickplayStats.MostPlayedHero = strings.TrimSpace(mostPlayedHeroQP)
+	ps.QuickplayStats.MostPlayedHeroTimePlayed = platform.ProfileView.
+		Find(".Profile-heroSummary--view.quickPlay-view").
+		Find(".Profile-progressBar-description").
+		First().
+		Text()
+
+	if heroStats, ok := careerStatsQP[cleanJSONKey(ps.QuickplayStats.MostPlayedHero)]; ok {
+		if val, ok := heroStats.Game["gamesPlayed"]; ok {
+			if i, ok := val.(int); ok {
+				ps.QuickplayStats.MostPlayedHeroGamesPlayed = i
+			}
+		}
+		ps.QuickplayStats.MostPlayedHeroWinPercentage = statInt(heroStats.Game, "winPercentage")
+	}
+
+	return &ps, nil
+}
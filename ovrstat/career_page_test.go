@@ -0,0 +1,157 @@
+package ovrstat
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/career")
+
+// goldenPlatform is what a career page yields for one platform
+type goldenPlatform struct {
+	Stats        *PlayerStats        `json:"stats,omitempty"`
+	StatsError   string              `json:"statsError,omitempty"`
+	Profile      *PlayerStatsProfile `json:"profile,omitempty"`
+	ProfileError string              `json:"profileError,omitempty"`
+}
+
+// goldenResult is the serialized outcome of parsing one captured page
+type goldenResult struct {
+	Error     string                     `json:"error,omitempty"`
+	Private   bool                       `json:"private"`
+	Platforms map[string]*goldenPlatform `json:"platforms,omitempty"`
+}
+
+func parseGolden(t *testing.T, file string) []byte {
+	t.Helper()
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := &CareerPage{
+		CareerID: "Tester-1234|0123456789abcdef",
+		Player: &Player{
+			BattleTag: "Tester#1234",
+			IsPublic:  true,
+			Namecard:  "https://d15f34w2p8l1cc.cloudfront.net/overwatch/namecard.png",
+		},
+	}
+
+	var res goldenResult
+	if err := page.parse(doc); err != nil {
+		res.Error = err.Error()
+	} else {
+		res.Private = page.Private
+		res.Platforms = make(map[string]*goldenPlatform)
+		for _, platform := range []string{PlatformPC, PlatformConsole} {
+			gp := new(goldenPlatform)
+			if gp.Stats, err = page.Stats(platform); err != nil {
+				gp.StatsError = err.Error()
+			}
+			if gp.Profile, err = page.ProfileStats(platform); err != nil {
+				gp.ProfileError = err.Error()
+			}
+			res.Platforms[platform] = gp
+		}
+	}
+
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(out, '\n')
+}
+
+// TestCareerPageGolden parses every captured page in testdata/career and
+// compares the result with its .golden.json file. After an intended parser
+// change, regenerate them with:
+//
+//	go test ./ovrstat -run TestCareerPageGolden -update
+func TestCareerPageGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "career", "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no captured career pages in testdata/career")
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".html")
+		t.Run(name, func(t *testing.T) {
+			got := parseGolden(t, file)
+			golden := strings.TrimSuffix(file, ".html") + ".golden.json"
+
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("parsed output differs from %s; review the change and run with -update if intended\ngot:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestCleanJSONKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"All Heroes", "allHeroes"},
+		{"Soldier: 76", "soldier76"},
+		{"Lúcio", "lucio"},
+		{"D.Va", "dVa"},
+		{"Torbjörn", "torbjorn"},
+		{"Wrecking Ball", "wreckingBall"},
+		{"Multikill - Best", "multikillBest"},
+		{"Eliminations per Life", "eliminationsPerLife"},
+		{"Hero Specific", "heroSpecific"},
+		{"{count, plural, one {Card} other {Cards}}", "cards"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := cleanJSONKey(tt.in); got != tt.want {
+			t.Errorf("cleanJSONKey(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTransformKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"eliminationMostInGame", "eliminationsMostInGame"},
+		{"killStreakBest", "killsStreakBest"},
+		{"allDamageDone", "damageDone"},
+		{"timePlayed", "timePlayed"},
+	}
+	for _, tt := range tests {
+		if got := transformKey(tt.in); got != tt.want {
+			t.Errorf("transformKey(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package ovrstat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultBaseURL is the Blizzard site all requests are made against
+	DefaultBaseURL = "https://overwatch.blizzard.com"
+
+	// DefaultLocale is the locale segment used in Blizzard URLs
+	DefaultLocale = "en-us"
+
+	// DefaultUserAgent is sent with every request unless overridden
+	DefaultUserAgent = "Mozilla/5.0 (compatible; ovrstat)"
+
+	// browserUserAgent is used for the unlocks endpoint, which only answers
+	// requests that look like they come from the search page in a browser
+	browserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/135.0.0.0 Safari/537.36"
+)
+
+// Client scrapes Overwatch career data from Blizzard. Create one with
+// NewClient; a Client is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	locale     string
+	userAgent  string
+	httpClient *http.Client
+	transport  http.RoundTripper
+	limiter    *Limiter
+	logger     *log.Logger
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithBaseURL points the client at a different host, e.g. an httptest server
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithLocale sets the locale segment used in Blizzard URLs (default "en-us")
+func WithLocale(locale string) Option {
+	return func(c *Client) {
+		c.locale = strings.Trim(locale, "/")
+	}
+}
+
+// WithUserAgent overrides the User-Agent sent with every request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithHTTPClient sets the http.Client used for all requests. A client without
+// a cookie jar will not keep the session primed between requests.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithTransport sets the transport of the client's http.Client
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = rt
+	}
+}
+
+// WithDebugLogger enables debug logging for this client only
+func WithDebugLogger(l *log.Logger) Option {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// NewClient creates a new Client with the passed options applied
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		baseURL: DefaultBaseURL,
+		locale:  DefaultLocale,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		jar, _ := cookiejar.New(nil)
+		c.httpClient = &http.Client{
+			Timeout: 15 * time.Second,
+			Jar:     jar,
+		}
+	}
+	if c.transport != nil {
+		hc := *c.httpClient
+		hc.Transport = c.transport
+		c.httpClient = &hc
+	}
+	if c.limiter != nil {
+		hc := *c.httpClient
+		next := hc.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		hc.Transport = &limitedTransport{next: next, limiter: c.limiter}
+		c.httpClient = &hc
+	}
+	return c
+}
+
+// defaultClient backs the package level functions
+var defaultClient = NewClient()
+
+// debugf logs to the client's debug logger, or to stdout when the package
+// level Debug flag is set
+func (c *Client) debugf(format string, args ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf("[DEBUG] "+format, args...)
+		return
+	}
+	if Debug {
+		fmt.Printf("[DEBUG] "+format+"\n", args...)
+	}
+}
+
+func (c *Client) siteURL(p string) string {
+	return c.baseURL + "/" + c.locale + p
+}
+
+func (c *Client) careerURL() string {
+	return c.siteURL("/career")
+}
+
+func (c *Client) searchURL() string {
+	return c.siteURL("/search/")
+}
+
+// userAgentOr returns the configured user agent, falling back to def
+func (c *Client) userAgentOr(def string) string {
+	if c.userAgent != "" {
+		return c.userAgent
+	}
+	return def
+}
+
+func (c *Client) newRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgentOr(DefaultUserAgent))
+	return req, nil
+}
+
+func (c *Client) primeSession(ctx context.Context) error {
+	u := c.searchURL()
+	req, err := c.newRequest(ctx, u)
+	if err != nil {
+		return fmt.Errorf("prime build request: %w", err)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+	c.debugf("Prime Request URL: %s", u)
+	c.debugf("Prime Request Headers: %v", req.Header)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return requestErr("prime", err)
+	}
+	defer resp.Body.Close()
+
+	c.debugf("Prime Response Status: %d %s", resp.StatusCode, resp.Status)
+
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		if err := statusErr("prime", resp.StatusCode); err != nil {
+			return err
+		}
+		return fmt.Errorf("prime session failed: %d %s", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
+
+func splitTag(tag string) (name string, full string) {
+	full = strings.ReplaceAll(tag, "-", "#")
+	if idx := strings.Index(full, "#"); idx != -1 {
+		name = full[:idx]
+	} else {
+		name = full
+	}
+	return
+}
+
+// searchPlayer looks up the search metadata (privacy, namecard) of a player
+// whose existence was already confirmed through resolveCareerID
+func (c *Client) searchPlayer(ctx context.Context, tag string) *Player {
+	// 1️⃣ Name extrahieren
+	name, full := splitTag(tag)
+
+	// 2️⃣ Search nur noch als Metadatenquelle
+	playersByName, _ := c.retrievePlayers(ctx, name)
+	playersByFull, _ := c.retrievePlayers(ctx, full)
+
+	// 3️⃣ Wenn Full-Search was liefert → nehmen
+	if len(playersByFull) > 0 {
+		return &playersByFull[0]
+	}
+
+	// 4️⃣ Fallback: Name-Search (einziger Treffer)
+	if len(playersByName) == 1 {
+		return &playersByName[0]
+	}
+
+	// 5️⃣ Existiert zwar, aber nicht eindeutig auffindbar
+	return &Player{
+		BattleTag: strings.ReplaceAll(tag, "-", "#"),
+		IsPublic:  true, // unknown → default
+	}
+}
+
+// UnlockInfoContext converts an unlock ID from the search API into its name
+// and icon
+func (c *Client) UnlockInfoContext(ctx context.Context, unlockID string) (*UnlockData, error) {
+	// Session Prepare
+	if err := c.primeSession(ctx); err != nil {
+		return nil, err
+	}
+
+	q := url.Values{}
+	q.Set("unlockIds", unlockID)
+	fullURL := c.searchURL() + "unlocks/?" + q.Encode()
+
+	req, err := c.newRequest(ctx, fullURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "*/*")
+	req.Header.Set("User-Agent", c.userAgentOr(browserUserAgent))
+	req.Header.Set("Referer", c.searchURL())
+	req.Header.Set("Accept-Language", "en,de-DE;q=0.9,de;q=0.8,en-US;q=0.7")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.Header.Set("Sec-Fetch-Dest", "empty")
+	req.Header.Set("Sec-Fetch-Mode", "cors")
+	req.Header.Set("Sec-Fetch-Site", "same-origin")
+	req.Header.Set("Sec-CH-UA", "\"Chromium\";v=\"135\", \"Not-A.Brand\";v=\"99\"")
+	req.Header.Set("Sec-CH-UA-Mobile", "?0")
+	req.Header.Set("Sec-CH-UA-Platform", "\"Windows\"")
+
+	c.debugf("Unlock Request URL: %s", fullURL)
+	c.debugf("Unlock Request Headers: %v", req.Header)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, requestErr("unlocks", err)
+	}
+	defer resp.Body.Close()
+
+	c.debugf("Unlock Response Status: %d %s", resp.StatusCode, resp.Status)
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		c.debugf("Unlock Response Body: %s", string(b))
+		if err := statusErr("unlocks", resp.StatusCode); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unlocks %d: %s", resp.StatusCode, string(b))
+	}
+
+	var unlocks []UnlockData
+	if err := json.NewDecoder(resp.Body).Decode(&unlocks); err != nil {
+		return nil, parseErr("unlocks", err)
+	}
+
+	c.debugf("Decoded Unlocks (%d): %+v", len(unlocks), unlocks)
+
+	for _, u := range unlocks {
+		if u.ID == unlockID {
+			c.debugf("Match found: %v", u)
+			return &u, nil
+		}
+	}
+
+	c.debugf("Unlock not found for ID: %s", unlockID)
+	return nil, fmt.Errorf("Unlock ID %s not found", unlockID)
+}
+
+// careerIDFromPath extracts the name|hash career ID from a career URL or path
+func careerIDFromPath(p string) string {
+	if u, err := url.Parse(p); err == nil {
+		p = u.Path
+	}
+	p, _ = url.PathUnescape(p)
+	if idx := strings.Index(p, "/career/"); idx != -1 {
+		p = p[idx+len("/career/"):]
+	}
+	p = strings.Trim(p, "/")
+	if !strings.Contains(p, "|") {
+		return ""
+	}
+	return p
+}
+
+func (c *Client) resolveCareerID(ctx context.Context, tag string) (string, error) {
+	tag = strings.ReplaceAll(tag, "#", "-")
+	startURL := c.careerURL() + "/" + url.PathEscape(tag)
+
+	c.debugf("resolveCareerID input tag: %s", tag)
+	c.debugf("resolveCareerID URL: %s", startURL)
+
+	// Follow redirects by hand so the career ID can be read off the Location
+	// header, sharing the jar and transport of the regular client
+	client := *c.httpClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	req, err := c.newRequest(ctx, startURL)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/html")
+
+	for i := 0; i < 5; i++ {
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", requestErr("resolve", err)
+		}
+
+		c.debugf("Redirect step %d", i)
+		c.debugf("Status: %d", resp.StatusCode)
+		c.debugf("Location: %s", resp.Header.Get("Location"))
+		c.debugf("Final URL: %s", resp.Request.URL.String())
+
+		// 🔥 FALL 1: Redirect mit Location
+		if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+			loc := resp.Header.Get("Location")
+			resp.Body.Close()
+			if loc == "" {
+				return "", parseErr("resolve", errors.New("redirect without location"))
+			}
+
+			if id := careerIDFromPath(loc); id != "" {
+				return id, nil
+			}
+
+			next, err := resp.Request.URL.Parse(loc)
+			if err != nil {
+				return "", parseErr("resolve", errors.Wrap(err, "invalid redirect location"))
+			}
+
+			req, err = c.newRequest(ctx, next.String())
+			if err != nil {
+				return "", err
+			}
+			req.Header.Set("Accept", "text/html")
+			continue
+		}
+
+		// 🔥 FALL 2: Finaler 200-Request → URL auswerten
+		resp.Body.Close()
+		if err := statusErr("resolve", resp.StatusCode); err != nil {
+			return "", err
+		}
+		if resp.StatusCode == http.StatusOK {
+			if id := careerIDFromPath(resp.Request.URL.Path); id != "" {
+				return id, nil
+			}
+		}
+		break
+	}
+
+	c.debugf("resolveCareerID FAILED for tag: %s", tag)
+
+	return "", ErrPlayerNotFound
+}
+
+func (c *Client) retrievePlayers(ctx context.Context, tag string) ([]Player, error) {
+	if strings.Contains(tag, "-") {
+		tag = strings.Replace(tag, "-", "#", -1)
+	}
+	// Perform api request
+	var platforms []Player
+
+	// API URL Use '#' as A Delimiter between Number and Name and not '-'
+	req, err := c.newRequest(ctx, c.searchURL()+"account-by-name/"+url.PathEscape(tag))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to build platform API request")
+	}
+
+	apires, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(requestErr("search", err), "Failed to perform platform API request")
+	}
+
+	defer apires.Body.Close()
+
+	if err := statusErr("search", apires.StatusCode); err != nil {
+		return nil, err
+	}
+
+	// Decode received JSON
+	if err := json.NewDecoder(apires.Body).Decode(&platforms); err != nil {
+		return nil, errors.Wrap(parseErr("search", err), "Failed to decode platform API response")
+	}
+	return platforms, nil
+}
@@ -0,0 +1,151 @@
+package ovrstat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testCareerPage = `<html><body>
+<div class="Profile-masthead">
+	<h1 class="Profile-player--name">Foo</h1>
+	<div class="Profile-player--filters">
+		<div class="Profile-player--filter" id="mouseKeyboardFilter">PC</div>
+	</div>
+</div>
+<div class="Profile-view mouseKeyboard-view"></div>
+</body></html>`
+
+// newTestServer fakes the parts of the Blizzard site the client talks to
+func newTestServer(t *testing.T, career http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/en-us/career/Foo-1234", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/en-us/career/Foo-1234%7Cabc123/", http.StatusFound)
+	})
+	mux.HandleFunc("/en-us/career/Foo-1234|abc123/", career)
+	mux.HandleFunc("/en-us/search/account-by-name/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"battleTag":"Foo#1234","isPublic":true,"namecard":"namecard.png"}]`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClientStatsContext(t *testing.T) {
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testCareerPage))
+	})
+	c := NewClient(WithBaseURL(srv.URL))
+
+	ps, err := c.StatsContext(context.Background(), PlatformPC, "Foo-1234")
+	if err != nil {
+		t.Fatalf("StatsContext: %v", err)
+	}
+	if ps.Name != "Foo" {
+		t.Errorf("Name = %q; want %q", ps.Name, "Foo")
+	}
+	if ps.NamecardImage != "namecard.png" {
+		t.Errorf("NamecardImage = %q; want %q", ps.NamecardImage, "namecard.png")
+	}
+
+	if _, err := c.StatsContext(context.Background(), PlatformConsole, "Foo-1234"); err != ErrInvalidPlatform {
+		t.Errorf("console err = %v; want %v", err, ErrInvalidPlatform)
+	}
+}
+
+func TestClientStatsContextCancel(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	c := NewClient(WithBaseURL(srv.URL))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.ProfileStatsContext(ctx, PlatformPC, "Foo-1234")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v; want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("lookup returned after %s; want it cancelled with the context", d)
+	}
+}
+
+func TestCareerPageSingleFetch(t *testing.T) {
+	var fetches int
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Write([]byte(testCareerPage))
+	})
+	c := NewClient(WithBaseURL(srv.URL))
+
+	page, err := c.CareerPageContext(context.Background(), "Foo-1234")
+	if err != nil {
+		t.Fatalf("CareerPageContext: %v", err)
+	}
+	if page.CareerID != "Foo-1234|abc123" {
+		t.Errorf("CareerID = %q; want %q", page.CareerID, "Foo-1234|abc123")
+	}
+	if _, err := page.Stats(PlatformPC); err != nil {
+		t.Errorf("Stats: %v", err)
+	}
+	if _, err := page.ProfileStats(PlatformPC); err != nil {
+		t.Errorf("ProfileStats: %v", err)
+	}
+	if fetches != 1 {
+		t.Errorf("career page fetched %d times; want 1", fetches)
+	}
+}
+
+func TestClientLimiter(t *testing.T) {
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testCareerPage))
+	})
+	l := NewLimiter(LimiterConfig{Rate: 0.001, Burst: 1, MaxWait: 50 * time.Millisecond})
+	c := NewClient(WithBaseURL(srv.URL), WithLimiter(l))
+
+	// The career ID redirect gets the only token, the request it leads to
+	// must be refused without reaching the server
+	_, err := c.StatsContext(context.Background(), PlatformPC, "Foo-1234")
+	if !errors.Is(err, ErrRateLimitedLocally) {
+		t.Errorf("StatsContext = %v; want ErrRateLimitedLocally", err)
+	}
+}
+
+func TestClientUpstreamErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusServiceUnavailable, ErrUpstreamUnavailable},
+		{http.StatusBadGateway, ErrUpstreamUnavailable},
+		{http.StatusTooManyRequests, ErrUpstreamRateLimited},
+	}
+	for _, tt := range tests {
+		srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		})
+		c := NewClient(WithBaseURL(srv.URL))
+
+		_, err := c.StatsContext(context.Background(), PlatformPC, "Foo-1234")
+		if !errors.Is(err, tt.want) {
+			t.Errorf("status %d: err = %v; want %v", tt.status, err, tt.want)
+			continue
+		}
+		var ue *UpstreamError
+		if !errors.As(err, &ue) || ue.Op != "career" || ue.StatusCode != tt.status {
+			t.Errorf("status %d: err = %#v; want an UpstreamError for the career page", tt.status, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package ovrstat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrUpstreamUnavailable is returned when Blizzard could not be reached or
+	// answered with a server error, e.g. during maintenance
+	ErrUpstreamUnavailable = errors.New("Blizzard is unavailable")
+
+	// ErrUpstreamRateLimited is returned when Blizzard refused a request with
+	// 429 Too Many Requests
+	ErrUpstreamRateLimited = errors.New("rate limited by Blizzard")
+
+	// ErrParseFailure is returned when a response from Blizzard could not be
+	// decoded at all
+	ErrParseFailure = errors.New("failed to parse Blizzard response")
+
+	// ErrProfileLayoutChanged is returned when a career page decodes fine but
+	// lacks the elements the parser relies on
+	ErrProfileLayoutChanged = errors.New("career page layout changed")
+)
+
+// UpstreamError describes a failed request to Blizzard. It matches its Kind
+// with errors.Is, so callers only need to compare against the Err* values.
+type UpstreamError struct {
+	// Op is the request that failed: "career", "resolve", "search",
+	// "unlocks" or "prime"
+	Op string
+
+	// StatusCode is the HTTP status Blizzard answered with, 0 if it didn't
+	StatusCode int
+
+	// Kind is one of ErrUpstreamUnavailable, ErrUpstreamRateLimited,
+	// ErrParseFailure or ErrProfileLayoutChanged
+	Kind error
+
+	// Err is the underlying error, if any
+	Err error
+}
+
+func (e *UpstreamError) Error() string {
+	msg := e.Kind.Error() + " (" + e.Op
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(": %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	msg += ")"
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Is reports whether target is the kind of this error
+func (e *UpstreamError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+// requestErr classifies an error returned by http.Client.Do. Cancellation and
+// the client's own limiter are passed through unchanged.
+func requestErr(op string, err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, ErrRateLimitedLocally) {
+		return err
+	}
+	return &UpstreamError{Op: op, Kind: ErrUpstreamUnavailable, Err: err}
+}
+
+// statusErr classifies an unexpected response status, returning nil for the
+// ones that aren't upstream failures
+func statusErr(op string, status int) error {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return &UpstreamError{Op: op, StatusCode: status, Kind: ErrUpstreamRateLimited}
+	case status >= 500:
+		return &UpstreamError{Op: op, StatusCode: status, Kind: ErrUpstreamUnavailable}
+	}
+	return nil
+}
+
+// parseErr wraps a decoding failure
+func parseErr(op string, err error) error {
+	return &UpstreamError{Op: op, Kind: ErrParseFailure, Err: err}
+}
@@ -0,0 +1,154 @@
+package ovrstat
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// HeroStats holds the stats of a single hero in both play modes
+type HeroStats struct {
+	Hero        string        `json:"hero"`
+	QuickPlay   HeroModeStats `json:"quickPlay"`
+	Competitive HeroModeStats `json:"competitive"`
+}
+
+// HeroModeStats holds the stats of a single hero in one play mode. Both are
+// nil when the hero wasn't played in that mode.
+type HeroModeStats struct {
+	TopHeroStats *TopHeroStats `json:"topHeroStats"`
+	CareerStats  *CareerStats  `json:"careerStats"`
+}
+
+// heroAliases maps common alternative names to the keys produced by
+// cleanJSONKey. Both sides are compared with foldHeroKey.
+var heroAliases = map[string]string{
+	"all":     "allHeroes",
+	"overall": "allHeroes",
+	"soldier": "soldier76",
+	"s76":     "soldier76",
+	"76":      "soldier76",
+	"hana":    "dVa",
+	"mccree":  "cassidy",
+	"cass":    "cassidy",
+	"torb":    "torbjorn",
+	"hammond": "wreckingBall",
+	"ball":    "wreckingBall",
+	"jq":      "junkerQueen",
+	"widow":   "widowmaker",
+	"rein":    "reinhardt",
+	"zen":     "zenyatta",
+	"brig":    "brigitte",
+	"sym":     "symmetra",
+	"junk":    "junkrat",
+	"hog":     "roadhog",
+}
+
+// heroNames are the heroes as the career page names them, so hero names can
+// be checked without scraping a player. New heroes have to be added here.
+var heroNames = []string{
+	"All Heroes", "Ana", "Ashe", "Baptiste", "Bastion", "Brigitte", "Cassidy",
+	"D.Va", "Doomfist", "Echo", "Freja", "Genji", "Hanzo", "Hazard", "Illari",
+	"Junker Queen", "Junkrat", "Juno", "Kiriko", "Lifeweaver", "Lúcio",
+	"Mauga", "Mei", "Mercy", "Moira", "Orisa", "Pharah", "Ramattra", "Reaper",
+	"Reinhardt", "Roadhog", "Sigma", "Sojourn", "Soldier: 76", "Sombra",
+	"Symmetra", "Torbjörn", "Tracer", "Venture", "Widowmaker", "Winston",
+	"Wrecking Ball", "Wuyang", "Zarya", "Zenyatta",
+}
+
+// heroKeys maps the folded keys of heroNames to the keys produced by
+// cleanJSONKey
+var heroKeys = func() map[string]string {
+	keys := make(map[string]string, len(heroNames))
+	for _, name := range heroNames {
+		key := cleanJSONKey(name)
+		keys[foldHeroKey(key)] = key
+	}
+	return keys
+}()
+
+// KnownHeroes returns the sorted keys of every known hero
+func KnownHeroes() []string {
+	keys := make([]string, 0, len(heroKeys))
+	for _, key := range heroKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// HeroKey returns the key of a known hero, given as for Hero. It returns
+// false for names that are no known hero.
+func HeroKey(name string) (string, bool) {
+	want := foldHeroKey(name)
+	if alias, ok := heroAliases[want]; ok {
+		want = foldHeroKey(alias)
+	}
+	key, ok := heroKeys[want]
+	return key, ok
+}
+
+// foldHeroKey reduces a hero name or key to lowercase letters and digits, so
+// "Soldier: 76", "soldier-76" and "soldier76" all compare equal
+func foldHeroKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, cleanJSONKey(name))
+}
+
+// HeroKeys returns the sorted keys of every hero the player has stats for in
+// any play mode
+func (ps *PlayerStats) HeroKeys() []string {
+	seen := make(map[string]bool)
+	for _, sc := range []StatsCollection{ps.QuickPlayStats.StatsCollection, ps.CompetitiveStats.StatsCollection} {
+		for key := range sc.TopHeroes {
+			seen[key] = true
+		}
+		for key := range sc.CareerStats {
+			seen[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Hero returns the stats of a single hero. The name may be a key as produced
+// for topHeroes/careerStats ("soldier76"), the display name ("Soldier: 76") or
+// a common alias ("soldier-76", "lucio", "dva"). Known heroes the player has
+// no stats for are returned without stats, it returns false only for names
+// that are neither a known hero nor one the player has stats for.
+func (ps *PlayerStats) Hero(name string) (*HeroStats, bool) {
+	want := foldHeroKey(name)
+	if alias, ok := heroAliases[want]; ok {
+		want = foldHeroKey(alias)
+	}
+
+	for _, key := range ps.HeroKeys() {
+		if foldHeroKey(key) != want {
+			continue
+		}
+		return &HeroStats{
+			Hero: key,
+			QuickPlay: HeroModeStats{
+				TopHeroStats: ps.QuickPlayStats.TopHeroes[key],
+				CareerStats:  ps.QuickPlayStats.CareerStats[key],
+			},
+			Competitive: HeroModeStats{
+				TopHeroStats: ps.CompetitiveStats.TopHeroes[key],
+				CareerStats:  ps.CompetitiveStats.CareerStats[key],
+			},
+		}, true
+	}
+	if key, ok := heroKeys[want]; ok {
+		return &HeroStats{Hero: key}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,83 @@
+package ovrstat
+
+import "testing"
+
+func TestPlayerStatsHero(t *testing.T) {
+	ps := &PlayerStats{}
+	ps.QuickPlayStats.TopHeroes = map[string]*TopHeroStats{
+		"soldier76": {GamesWon: 3},
+		"lucio":     {GamesWon: 1},
+	}
+	ps.CompetitiveStats.CareerStats = map[string]*CareerStats{
+		"wreckingBall": {},
+		"dVa":          {},
+	}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"soldier76", "soldier76"},
+		{"soldier-76", "soldier76"},
+		{"Soldier: 76", "soldier76"},
+		{"s76", "soldier76"},
+		{"lucio", "lucio"},
+		{"Lúcio", "lucio"},
+		{"dva", "dVa"},
+		{"D.Va", "dVa"},
+		{"ball", "wreckingBall"},
+		{"wrecking-ball", "wreckingBall"},
+	}
+	for _, tt := range tests {
+		hs, ok := ps.Hero(tt.in)
+		if !ok {
+			t.Errorf("Hero(%q) not found; want %q", tt.in, tt.want)
+			continue
+		}
+		if hs.Hero != tt.want {
+			t.Errorf("Hero(%q) = %q; want %q", tt.in, hs.Hero, tt.want)
+		}
+	}
+
+	// Known heroes without stats are found without stats, unknown ones not
+	if hs, ok := ps.Hero("genji"); !ok || hs.Hero != "genji" || hs.QuickPlay.TopHeroStats != nil {
+		t.Errorf("Hero(\"genji\") = %+v, %v; want genji without stats", hs, ok)
+	}
+	if _, ok := ps.Hero("gengi"); ok {
+		t.Error("Hero(\"gengi\") found an unknown hero")
+	}
+	hs, _ := ps.Hero("soldier76")
+	if hs.QuickPlay.TopHeroStats.GamesWon != 3 || hs.Competitive.TopHeroStats != nil {
+		t.Errorf("Hero(\"soldier76\") = %+v", hs)
+	}
+	if keys := ps.HeroKeys(); len(keys) != 4 || keys[0] != "dVa" {
+		t.Errorf("HeroKeys() = %v", keys)
+	}
+}
+
+func TestHeroKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"soldier-76", "soldier76", true},
+		{"Torbjörn", "torbjorn", true},
+		{"dva", "dVa", true},
+		{"jq", "junkerQueen", true},
+		{"all", "allHeroes", true},
+		{"gengi", "", false},
+	}
+	for _, tt := range tests {
+		if key, ok := HeroKey(tt.in); key != tt.want || ok != tt.ok {
+			t.Errorf("HeroKey(%q) = %q, %v; want %q, %v", tt.in, key, ok, tt.want, tt.ok)
+		}
+	}
+
+	// Every alias must point to a known hero
+	for alias, key := range heroAliases {
+		if _, ok := HeroKey(key); !ok {
+			t.Errorf("alias %q points to unknown hero %q", alias, key)
+		}
+	}
+}
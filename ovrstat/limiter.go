@@ -0,0 +1,227 @@
+package ovrstat
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrRateLimitedLocally is returned when an upstream request was not sent
+// because the client's own limiter had no budget left for it. Blizzard was
+// not contacted, so callers can safely fall back to cached data.
+var ErrRateLimitedLocally = errors.New("upstream request rate limited locally")
+
+// SharedBudget coordinates a request budget between several processes, e.g.
+// through Redis. Take reports whether one more request may be sent now.
+type SharedBudget interface {
+	Take(ctx context.Context) (bool, error)
+}
+
+// LimiterConfig configures a Limiter. Zero values disable the respective
+// limit.
+type LimiterConfig struct {
+	// Rate is the number of requests per second, Burst how many of them may
+	// be sent at once after a quiet period
+	Rate  float64
+	Burst int
+
+	// MaxInFlight caps the number of concurrent requests
+	MaxInFlight int
+
+	// MaxWait is how long a request waits for budget before failing with
+	// ErrRateLimitedLocally. Zero waits for as long as the context allows.
+	MaxWait time.Duration
+
+	// Shared is consulted in addition to the local limits
+	Shared SharedBudget
+}
+
+// Limiter limits the requests a Client sends to Blizzard. One Limiter can be
+// shared by several clients.
+type Limiter struct {
+	cfg      LimiterConfig
+	inFlight chan struct{}
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter creates a new Limiter from cfg
+func NewLimiter(cfg LimiterConfig) *Limiter {
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+	l := &Limiter{
+		cfg:    cfg,
+		tokens: float64(cfg.Burst),
+		last:   time.Now(),
+	}
+	if cfg.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, cfg.MaxInFlight)
+	}
+	return l
+}
+
+// WithLimiter makes the client send every request through l
+func WithLimiter(l *Limiter) Option {
+	return func(c *Client) {
+		c.limiter = l
+	}
+}
+
+// Acquire waits until a request may be sent and returns the function that
+// must be called once it is done
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	w := wait{parent: ctx, ctx: ctx}
+	if l.cfg.MaxWait > 0 {
+		var cancel context.CancelFunc
+		w.ctx, cancel = context.WithTimeout(ctx, l.cfg.MaxWait)
+		defer cancel()
+	}
+
+	// Take the in-flight slot first, so the rate budget isn't spent on a
+	// request that then can't be sent
+	release := func() {}
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+			var once sync.Once
+			release = func() {
+				once.Do(func() { <-l.inFlight })
+			}
+		case <-w.ctx.Done():
+			return nil, w.err()
+		}
+	}
+
+	if err := l.waitToken(w); err != nil {
+		release()
+		return nil, err
+	}
+	if err := l.waitShared(w); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// wait is one caller waiting for budget. ctx is parent bounded by MaxWait.
+type wait struct {
+	parent context.Context
+	ctx    context.Context
+}
+
+// err reports why waiting stopped: the caller's context if that is done,
+// otherwise the limiter ran out of time
+func (w wait) err() error {
+	if err := w.parent.Err(); err != nil {
+		return err
+	}
+	return ErrRateLimitedLocally
+}
+
+// sleep waits for d, failing early when the wait would run past its deadline
+func (w wait) sleep(d time.Duration) error {
+	if deadline, ok := w.ctx.Deadline(); ok && time.Until(deadline) < d {
+		return ErrRateLimitedLocally
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-w.ctx.Done():
+		return w.err()
+	}
+}
+
+// reserve takes a token if one is available, otherwise it returns how long
+// until the next one is
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.cfg.Rate
+	if burst := float64(l.cfg.Burst); l.tokens > burst {
+		l.tokens = burst
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.cfg.Rate * float64(time.Second))
+}
+
+func (l *Limiter) waitToken(w wait) error {
+	if l.cfg.Rate <= 0 {
+		return nil
+	}
+	for {
+		d := l.reserve()
+		if d == 0 {
+			return nil
+		}
+		if err := w.sleep(d); err != nil {
+			return err
+		}
+	}
+}
+
+// waitShared waits for the shared budget. It fails open: when the budget
+// can't be reached, only the local limits apply.
+func (l *Limiter) waitShared(w wait) error {
+	if l.cfg.Shared == nil {
+		return nil
+	}
+	for {
+		ok, err := l.cfg.Shared.Take(w.ctx)
+		if err != nil || ok {
+			return nil
+		}
+		// Shared budgets are counted per second, retry in the next one
+		next := time.Until(time.Now().Truncate(time.Second).Add(time.Second))
+		if err := w.sleep(next); err != nil {
+			return err
+		}
+	}
+}
+
+// limitedTransport sends requests only once the limiter allows it. The
+// in-flight slot is held until the response body is closed.
+type limitedTransport struct {
+	next    http.RoundTripper
+	limiter *Limiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.limiter.Acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releaseBody calls release once the body is closed
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,72 @@
+package ovrstat
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiterRate(t *testing.T) {
+	l := NewLimiter(LimiterConfig{Rate: 1, Burst: 2, MaxWait: 50 * time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		release, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("Acquire %d within burst: %v", i, err)
+		}
+		release()
+	}
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrRateLimitedLocally) {
+		t.Errorf("Acquire past burst = %v; want ErrRateLimitedLocally", err)
+	}
+}
+
+func TestLimiterMaxInFlight(t *testing.T) {
+	l := NewLimiter(LimiterConfig{MaxInFlight: 1, MaxWait: 50 * time.Millisecond})
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrRateLimitedLocally) {
+		t.Errorf("second Acquire = %v; want ErrRateLimitedLocally", err)
+	}
+
+	release()
+	release() // releasing twice must not free a second slot
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Errorf("Acquire after release: %v", err)
+	}
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrRateLimitedLocally) {
+		t.Errorf("Acquire after double release = %v; want ErrRateLimitedLocally", err)
+	}
+}
+
+func TestLimiterCallerCancel(t *testing.T) {
+	l := NewLimiter(LimiterConfig{MaxInFlight: 1})
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire with cancelled context = %v; want context.Canceled", err)
+	}
+}
+
+// sharedFunc adapts a function to SharedBudget
+type sharedFunc func() bool
+
+func (f sharedFunc) Take(context.Context) (bool, error) { return f(), nil }
+
+func TestLimiterShared(t *testing.T) {
+	l := NewLimiter(LimiterConfig{
+		MaxWait: 50 * time.Millisecond,
+		Shared:  sharedFunc(func() bool { return false }),
+	})
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrRateLimitedLocally) {
+		t.Errorf("Acquire with exhausted shared budget = %v; want ErrRateLimitedLocally", err)
+	}
+}
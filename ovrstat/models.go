@@ -19,6 +19,13 @@ type PlayerStats struct {
 	QuickPlayStats   QuickPlayStatsCollection   `json:"quickPlayStats"`
 	CompetitiveStats CompetitiveStatsCollection `json:"competitiveStats"`
 	Private          bool                       `json:"private"`
+
+	// CareerID is the name|hash ID of the career page, it survives BattleTag
+	// changes
+	CareerID string `json:"careerId,omitempty"`
+
+	// SchemaVersion is only set for responses in a schema other than SchemaV1
+	SchemaVersion int `json:"schemaVersion,omitempty"`
 }
 
 type PlayerStatsProfile struct {
@@ -34,6 +41,7 @@ type PlayerStatsProfile struct {
 	QuickplayStats   QuickplaySummary   `json:"quickplayStats,omitempty"`
 	Ratings          []Rating           `json:"ratings"`
 	Private          bool               `json:"private"`
+	CareerID         string             `json:"careerId,omitempty"`
 }
 
 type Rating struct {
@@ -116,6 +124,9 @@ type CareerStats struct {
 
 	// Deaths appears to have been removed, so we hide it.
 	Deaths map[string]interface{} `json:"deaths,omitempty"`
+
+	// Typed is only filled in the SchemaV2 shape, see Normalized
+	Typed *TypedCareerStats `json:"typed,omitempty"`
 }
 
 // Player represents a response from the search-by-name api request
@@ -0,0 +1,204 @@
+package ovrstat
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// SchemaV1 is the original response shape, career stat values are passed
+	// through as scraped ("49%", "12:34:56")
+	SchemaV1 = 1
+
+	// SchemaV2 normalizes career stat values: percentages become numbers,
+	// durations become seconds and counts become integers. Every career stats
+	// category also gets a typed summary of the well-known keys.
+	SchemaV2 = 2
+)
+
+// TypedCareerStats holds the well-known career stats of a hero in fixed
+// units. Durations are in seconds, percentages in the range 0-100.
+type TypedCareerStats struct {
+	TimePlayed          int     `json:"timePlayed"`
+	GamesPlayed         int     `json:"gamesPlayed"`
+	GamesWon            int     `json:"gamesWon"`
+	GamesLost           int     `json:"gamesLost"`
+	WinPercentage       float64 `json:"winPercentage"`
+	Eliminations        int     `json:"eliminations"`
+	FinalBlows          int     `json:"finalBlows"`
+	Deaths              int     `json:"deaths"`
+	SoloKills           int     `json:"soloKills"`
+	ObjectiveKills      int     `json:"objectiveKills"`
+	ObjectiveTime       int     `json:"objectiveTime"`
+	DamageDone          int     `json:"damageDone"`
+	HealingDone         int     `json:"healingDone"`
+	WeaponAccuracy      float64 `json:"weaponAccuracy"`
+	CriticalHitAccuracy float64 `json:"criticalHitAccuracy"`
+	EliminationsPerLife float64 `json:"eliminationsPerLife"`
+	KillStreakBest      int     `json:"killStreakBest"`
+	MultikillBest       int     `json:"multikillBest"`
+}
+
+// NormalizeValue converts a scraped stat value into its normalized form.
+// "49%" becomes 49, "12:34:56" and "03:21" become seconds and numeric strings
+// become numbers. Integral results are returned as int, all others as float64.
+// Values it does not understand are returned unchanged.
+func NormalizeValue(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+
+	s = strings.TrimSpace(strings.Replace(s, ",", "", -1))
+
+	if strings.HasSuffix(s, "%") {
+		if f, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64); err == nil {
+			return number(f)
+		}
+		return v
+	}
+
+	if secs, ok := parseDuration(s); ok {
+		return secs
+	}
+
+	if parsed := parseType(s); parsed != s {
+		return parsed
+	}
+	return v
+}
+
+// number returns f as an int when it has no fractional part
+func number(f float64) interface{} {
+	if f == float64(int(f)) {
+		return int(f)
+	}
+	return f
+}
+
+// parseDuration parses the "HH:MM:SS" and "MM:SS" durations used on the
+// career page into seconds
+func parseDuration(s string) (int, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+
+	secs := 0
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		secs = secs*60 + n
+	}
+	return secs, true
+}
+
+// statFloat returns the normalized value of the first of keys present in m
+// as a float64, or 0
+func statFloat(m map[string]interface{}, keys ...string) float64 {
+	for _, key := range keys {
+		v, ok := m[key]
+		if !ok {
+			continue
+		}
+		switch val := NormalizeValue(v).(type) {
+		case int:
+			return float64(val)
+		case float64:
+			return val
+		}
+		return 0
+	}
+	return 0
+}
+
+// statInt returns the normalized value of the first of keys present in m
+// truncated to an int, or 0
+func statInt(m map[string]interface{}, keys ...string) int {
+	return int(statFloat(m, keys...))
+}
+
+// normalizeMap returns a copy of m with every value normalized
+func normalizeMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = NormalizeValue(v)
+	}
+	return out
+}
+
+// Normalized returns a copy of the career stats with every value normalized
+// and the typed summary filled in
+func (cs *CareerStats) Normalized() *CareerStats {
+	if cs == nil {
+		return nil
+	}
+	out := &CareerStats{
+		Assists:      normalizeMap(cs.Assists),
+		Average:      normalizeMap(cs.Average),
+		Best:         normalizeMap(cs.Best),
+		Combat:       normalizeMap(cs.Combat),
+		HeroSpecific: normalizeMap(cs.HeroSpecific),
+		Game:         normalizeMap(cs.Game),
+		MatchAwards:  normalizeMap(cs.MatchAwards),
+		Deaths:       normalizeMap(cs.Deaths),
+	}
+	out.Typed = out.typed()
+	return out
+}
+
+// typed collects the well-known keys. Keys have gone through transformKey,
+// which is why some of them are pluralized ("criticalHitsAccuracy").
+func (cs *CareerStats) typed() *TypedCareerStats {
+	return &TypedCareerStats{
+		TimePlayed:          statInt(cs.Game, "timePlayed"),
+		GamesPlayed:         statInt(cs.Game, "gamesPlayed"),
+		GamesWon:            statInt(cs.Game, "gamesWon"),
+		GamesLost:           statInt(cs.Game, "gamesLost"),
+		WinPercentage:       statFloat(cs.Game, "winPercentage"),
+		Eliminations:        statInt(cs.Combat, "eliminations"),
+		FinalBlows:          statInt(cs.Combat, "finalBlows"),
+		Deaths:              statInt(cs.Combat, "deaths"),
+		SoloKills:           statInt(cs.Combat, "soloKills"),
+		ObjectiveKills:      statInt(cs.Combat, "objectiveKills"),
+		ObjectiveTime:       statInt(cs.Combat, "objectiveTime"),
+		DamageDone:          statInt(cs.Combat, "damageDone", "allDamageDone"),
+		HealingDone:         statInt(cs.Assists, "healingDone"),
+		WeaponAccuracy:      statFloat(cs.Combat, "weaponAccuracy"),
+		CriticalHitAccuracy: statFloat(cs.Combat, "criticalHitsAccuracy", "criticalHitAccuracy"),
+		EliminationsPerLife: statFloat(cs.Average, "eliminationsPerLife"),
+		KillStreakBest:      statInt(cs.Best, "killsStreakBest", "killStreakBest"),
+		MultikillBest:       statInt(cs.Best, "multikillsBest", "multikillBest"),
+	}
+}
+
+// normalizeCollection returns a copy of sc with normalized career stats. Top
+// heroes are shared with sc.
+func normalizeCollection(sc StatsCollection) StatsCollection {
+	out := StatsCollection{TopHeroes: sc.TopHeroes}
+	if sc.CareerStats != nil {
+		out.CareerStats = make(map[string]*CareerStats, len(sc.CareerStats))
+		for hero, cs := range sc.CareerStats {
+			out.CareerStats[hero] = cs.Normalized()
+		}
+	}
+	return out
+}
+
+// Normalized returns a copy of the stats in the SchemaV2 shape. The receiver
+// is not modified, so it is safe to call on shared (cached) stats.
+func (ps *PlayerStats) Normalized() *PlayerStats {
+	if ps == nil {
+		return nil
+	}
+	out := *ps
+	out.SchemaVersion = SchemaV2
+	out.QuickPlayStats.StatsCollection = normalizeCollection(ps.QuickPlayStats.StatsCollection)
+	out.CompetitiveStats.StatsCollection = normalizeCollection(ps.CompetitiveStats.StatsCollection)
+	return &out
+}
@@ -0,0 +1,65 @@
+package ovrstat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want interface{}
+	}{
+		{"49%", 49},
+		{"12.5%", 12.5},
+		{"12:34:56", 45296},
+		{"03:21", 201},
+		{"1,234", 1234},
+		{"0.52", 0.52},
+		{42, 42},
+		{1.5, 1.5},
+		{"--", "--"},
+		{"1:2:3:4", "1:2:3:4"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeValue(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NormalizeValue(%#v) = %#v; want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPlayerStatsNormalized(t *testing.T) {
+	ps := &PlayerStats{}
+	ps.QuickPlayStats.CareerStats = map[string]*CareerStats{
+		"allHeroes": {
+			Game: map[string]interface{}{
+				"timePlayed":    "01:00:05",
+				"gamesPlayed":   10,
+				"winPercentage": "55%",
+			},
+			Combat: map[string]interface{}{
+				"criticalHitsAccuracy": "9%",
+			},
+		},
+	}
+
+	got := ps.Normalized()
+
+	if got.SchemaVersion != SchemaV2 {
+		t.Errorf("SchemaVersion = %d; want %d", got.SchemaVersion, SchemaV2)
+	}
+	cs := got.QuickPlayStats.CareerStats["allHeroes"]
+	if cs.Game["timePlayed"] != 3605 {
+		t.Errorf("timePlayed = %#v; want 3605", cs.Game["timePlayed"])
+	}
+	want := TypedCareerStats{TimePlayed: 3605, GamesPlayed: 10, WinPercentage: 55, CriticalHitAccuracy: 9}
+	if *cs.Typed != want {
+		t.Errorf("Typed = %+v; want %+v", *cs.Typed, want)
+	}
+
+	// The receiver is shared with the cache and must stay in the v1 shape
+	orig := ps.QuickPlayStats.CareerStats["allHeroes"]
+	if orig.Game["timePlayed"] != "01:00:05" || orig.Typed != nil || ps.SchemaVersion != 0 {
+		t.Errorf("Normalized modified its receiver: %+v", orig)
+	}
+}
@@ -0,0 +1,140 @@
+package ovrstat
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// ErrInvalidTag is returned for tags that can't be a BattleTag or gamertag
+	ErrInvalidTag = errors.New("Invalid BattleTag")
+
+	// ErrInvalidCareerID is returned for malformed career IDs
+	ErrInvalidCareerID = errors.New("Invalid career ID")
+)
+
+// PlayerID identifies a player on a platform. Tags are parsed once, so
+// "Foo#1234", "Foo-1234" and "foo-1234" are the same player.
+type PlayerID struct {
+	// Platform is PlatformPC or PlatformConsole
+	Platform string
+
+	// Name is the name as entered, in Unicode NFC
+	Name string
+
+	// Number is the discriminator of a BattleTag. It is empty for console
+	// gamertags.
+	Number string
+}
+
+// ParsePlayerID parses and validates the tag of a player on platform. Tags
+// are BattleTags with "#" or "-" before the number. On consoles gamertags,
+// which have no number, are accepted as well.
+func ParsePlayerID(platform, tag string) (PlayerID, error) {
+	platform = strings.ToLower(strings.TrimSpace(platform))
+	if platform != PlatformPC && platform != PlatformConsole {
+		return PlayerID{}, ErrInvalidPlatform
+	}
+
+	tag = norm.NFC.String(strings.TrimSpace(tag))
+	if i := strings.LastIndexAny(tag, "#-"); i != -1 {
+		name, number := tag[:i], tag[i+1:]
+		if validBattleTagName(name) && validNumber(number) {
+			return PlayerID{Platform: platform, Name: name, Number: number}, nil
+		}
+	}
+	if platform == PlatformConsole && validGamertag(tag) {
+		return PlayerID{Platform: platform, Name: tag}, nil
+	}
+	return PlayerID{}, ErrInvalidTag
+}
+
+// validBattleTagName reports whether name is 2 to 12 letters or digits, not
+// starting with a digit. Letters of any script are allowed.
+func validBattleTagName(name string) bool {
+	n := utf8.RuneCountInString(name)
+	if n < 2 || n > 12 {
+		return false
+	}
+	for i, r := range name {
+		if i == 0 && unicode.IsDigit(r) {
+			return false
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// validNumber reports whether number is a BattleTag discriminator
+func validNumber(number string) bool {
+	if len(number) < 3 || len(number) > 8 {
+		return false
+	}
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// validGamertag reports whether tag is 1 to 16 letters, digits, spaces,
+// underscores or hyphens, which covers Xbox, PSN and Switch names
+func validGamertag(tag string) bool {
+	n := utf8.RuneCountInString(tag)
+	if n < 1 || n > 16 {
+		return false
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// BattleTag returns the tag as shown in game, e.g. "Foo#1234"
+func (id PlayerID) BattleTag() string {
+	if id.Number == "" {
+		return id.Name
+	}
+	return id.Name + "#" + id.Number
+}
+
+// Tag returns the tag as used in career page URLs, e.g. "Foo-1234"
+func (id PlayerID) Tag() string {
+	if id.Number == "" {
+		return id.Name
+	}
+	return id.Name + "-" + id.Number
+}
+
+// Key returns the canonical tag, e.g. "foo-1234". Names are case
+// insensitive, so it is the same for every spelling of the player.
+func (id PlayerID) Key() string {
+	return strings.ToLower(id.Tag())
+}
+
+// String returns platform and tag for logging, e.g. "pc/Foo#1234"
+func (id PlayerID) String() string {
+	return id.Platform + "/" + id.BattleTag()
+}
+
+// ParseCareerID validates a career ID passed by a client, e.g. as a URL path
+// parameter. Escaped IDs such as "abc%7Cdef" are unescaped.
+func ParseCareerID(s string) (string, error) {
+	if strings.Contains(s, "/") {
+		return "", ErrInvalidCareerID
+	}
+	id := careerIDFromPath(s)
+	if id == "" || strings.ContainsAny(id, "/?#: ") {
+		return "", ErrInvalidCareerID
+	}
+	return id, nil
+}
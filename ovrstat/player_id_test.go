@@ -0,0 +1,62 @@
+package ovrstat
+
+import "testing"
+
+func TestParsePlayerID(t *testing.T) {
+	tests := []struct {
+		platform, tag string
+		key           string
+		battleTag     string
+		err           error
+	}{
+		{"pc", "Foo#1234", "foo-1234", "Foo#1234", nil},
+		{"pc", "Foo-1234", "foo-1234", "Foo#1234", nil},
+		{"PC", " foo-1234 ", "foo-1234", "foo#1234", nil},
+		{"pc", "Ünïcødé#21234", "ünïcødé-21234", "Ünïcødé#21234", nil},
+		{"pc", "Ünïcødé#21234", "ünïcødé-21234", "Ünïcødé#21234", nil}, // decomposed
+		{"pc", "한국어#3456", "한국어-3456", "한국어#3456", nil},
+		{"console", "Foo-1234", "foo-1234", "Foo#1234", nil},
+		{"console", "Some Gamer_1", "some gamer_1", "Some Gamer_1", nil},
+		{"pc", "Some Gamer_1", "", "", ErrInvalidTag},
+		{"pc", "Foo", "", "", ErrInvalidTag},
+		{"pc", "1Foo#1234", "", "", ErrInvalidTag},
+		{"pc", "Foo:bar#1234", "", "", ErrInvalidTag},
+		{"pc", "Foo#12a4", "", "", ErrInvalidTag},
+		{"pc", "ThisNameIsTooLong#1234", "", "", ErrInvalidTag},
+		{"console", "Gamertag:with:colons", "", "", ErrInvalidTag},
+		{"xbox", "Foo#1234", "", "", ErrInvalidPlatform},
+	}
+	for _, tt := range tests {
+		id, err := ParsePlayerID(tt.platform, tt.tag)
+		if err != tt.err {
+			t.Errorf("ParsePlayerID(%q, %q) error = %v; want %v", tt.platform, tt.tag, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if id.Key() != tt.key || id.BattleTag() != tt.battleTag {
+			t.Errorf("ParsePlayerID(%q, %q) = %q, %q; want %q, %q",
+				tt.platform, tt.tag, id.Key(), id.BattleTag(), tt.key, tt.battleTag)
+		}
+	}
+}
+
+func TestParseCareerID(t *testing.T) {
+	tests := []struct {
+		in, want string
+		err      error
+	}{
+		{"abc123|def456", "abc123|def456", nil},
+		{"abc123%7Cdef456", "abc123|def456", nil},
+		{"abc123", "", ErrInvalidCareerID},
+		{"../abc|def", "", ErrInvalidCareerID},
+		{"abc|def:x", "", ErrInvalidCareerID},
+	}
+	for _, tt := range tests {
+		got, err := ParseCareerID(tt.in)
+		if got != tt.want || err != tt.err {
+			t.Errorf("ParseCareerID(%q) = %q, %v; want %q, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
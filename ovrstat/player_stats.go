@@ -1,17 +1,12 @@
 package ovrstat
 
 import (
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
-	"net/http/cookiejar"
+	"context"
 	"net/url"
 	"path"
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 	"unicode"
 
 	"github.com/PuerkitoBio/goquery"
@@ -19,16 +14,15 @@ import (
 )
 
 const (
-	baseURL = "https://overwatch.blizzard.com/en-us/career"
-
-	// API URL Use '#' as A Delimiter between Number and Name and not '-'
-	apiURL = "https://overwatch.blizzard.com/en-us/search/account-by-name/"
-
 	// PlatformPC is a platform for PCs (mouseKeyboard in the page)
 	PlatformPC = "pc"
 
 	// PlatformConsole is a consolidated platform of all consoles
 	PlatformConsole = "console"
+
+	// ParserVersion is increased whenever parsing of the career page changes
+	// the stats it produces, so cached stats can be traced back to it
+	ParserVersion = 1
 )
 
 var (
@@ -38,382 +32,47 @@ var (
 	// ErrInvalidPlatform is thrown when the passed params are incorrect
 	ErrInvalidPlatform = errors.New("Invalid platform")
 
-	owClient *http.Client
 	// Debug enables debug logging (set to false by default)
 	Debug = false
 )
 
-func getOWClient() *http.Client {
-	if owClient != nil {
-		return owClient
-	}
-	jar, _ := cookiejar.New(nil)
-	owClient = &http.Client{
-		Timeout: 15 * time.Second,
-		Jar:     jar,
-	}
-	if Debug {
-		fmt.Println("[DEBUG] http.Client initialisiert mit CookieJar")
-	}
-	return owClient
-}
-
-func primeOWSession(c *http.Client) error {
-	url := "https://overwatch.blizzard.com/en-us/search/"
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("prime build request: %w", err)
-	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; ovrstat)")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
-
-	if Debug {
-		fmt.Println("[DEBUG] Prime Request URL:", url)
-		fmt.Println("[DEBUG] Prime Request Headers:", req.Header)
-	}
-
-	resp, err := c.Do(req)
-	if err != nil {
-		return fmt.Errorf("prime do: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if Debug {
-		fmt.Printf("[DEBUG] Prime Response Status: %d %s\n", resp.StatusCode, resp.Status)
-	}
-
-	io.Copy(io.Discard, resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("prime session failed: %d %s", resp.StatusCode, resp.Status)
-	}
-	return nil
-}
-
-func splitTag(tag string) (name string, full string) {
-	full = strings.ReplaceAll(tag, "-", "#")
-	if idx := strings.Index(full, "#"); idx != -1 {
-		name = full[:idx]
-	} else {
-		name = full
-	}
-	return
-}
-
-func resolvePlayerByDoubleSearch(tag string) (*Player, error) {
-	// 1️⃣ Existenz über Career-Redirect prüfen
-	_, err := resolveCareerID(tag)
-	if err != nil {
-		return nil, ErrPlayerNotFound
-	}
-
-	// 2️⃣ Name extrahieren
-	name, full := splitTag(tag)
-
-	// 3️⃣ Search nur noch als Metadatenquelle
-	playersByName, _ := retrievePlayers(name)
-	playersByFull, _ := retrievePlayers(full)
-
-	// 4️⃣ Wenn Full-Search was liefert → nehmen
-	if len(playersByFull) > 0 {
-		return &playersByFull[0], nil
-	}
-
-	// 5️⃣ Fallback: Name-Search (einziger Treffer)
-	if len(playersByName) == 1 {
-		return &playersByName[0], nil
-	}
-
-	// 6️⃣ Existiert zwar, aber nicht eindeutig auffindbar
-	return &Player{
-		BattleTag: strings.ReplaceAll(tag, "-", "#"),
-		IsPublic:  true, // unknown → default
-	}, nil
-}
-
-// Adding function to convert ID from Search to usable URL
+// GetUnlockInfo converts an unlock ID from the search API into its name and
+// icon using the default client
 func GetUnlockInfo(unlockID string) (*UnlockData, error) {
-	c := getOWClient()
-
-	// Session Prepare
-	if err := primeOWSession(c); err != nil {
-		return nil, err
-	}
-
-	base := "https://overwatch.blizzard.com/en-us/search/unlocks/"
-	q := url.Values{}
-	q.Set("unlockIds", unlockID)
-	fullURL := base + "?" + q.Encode()
-
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/135.0.0.0 Safari/537.36")
-	req.Header.Set("Referer", "https://overwatch.blizzard.com/en-us/search/")
-	req.Header.Set("Accept-Language", "en,de-DE;q=0.9,de;q=0.8,en-US;q=0.7")
-	req.Header.Set("X-Requested-With", "XMLHttpRequest")
-	req.Header.Set("Sec-Fetch-Dest", "empty")
-	req.Header.Set("Sec-Fetch-Mode", "cors")
-	req.Header.Set("Sec-Fetch-Site", "same-origin")
-	req.Header.Set("Sec-CH-UA", "\"Chromium\";v=\"135\", \"Not-A.Brand\";v=\"99\"")
-	req.Header.Set("Sec-CH-UA-Mobile", "?0")
-	req.Header.Set("Sec-CH-UA-Platform", "\"Windows\"")
-
-	if Debug {
-		fmt.Println("[DEBUG] Unlock Request URL:", fullURL)
-		fmt.Println("[DEBUG] Unlock Request Headers:", req.Header)
-	}
-
-	resp, err := c.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if Debug {
-		fmt.Printf("[DEBUG] Unlock Response Status: %d %s\n", resp.StatusCode, resp.Status)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		b, _ := io.ReadAll(resp.Body)
-		if Debug {
-			fmt.Println("[DEBUG] Unlock Response Body:", string(b))
-		}
-		return nil, fmt.Errorf("unlocks %d: %s", resp.StatusCode, string(b))
-	}
-
-	var unlocks []UnlockData
-	if err := json.NewDecoder(resp.Body).Decode(&unlocks); err != nil {
-		return nil, err
-	}
-
-	if Debug {
-		fmt.Printf("[DEBUG] Decoded Unlocks (%d): %+v\n", len(unlocks), unlocks)
-	}
-
-	for _, u := range unlocks {
-		if u.ID == unlockID {
-			if Debug {
-				fmt.Println("[DEBUG] Match found:", u)
-			}
-			return &u, nil
-		}
-	}
-
-	if Debug {
-		fmt.Println("[DEBUG] Unlock not found for ID:", unlockID)
-	}
-	return nil, fmt.Errorf("Unlock ID %s not found", unlockID)
-}
-
-func resolveCareerID(tag string) (string, error) {
-	tag = strings.ReplaceAll(tag, "#", "-")
-
-	if Debug {
-		fmt.Println("[DEBUG] resolveCareerID input tag:", tag)
-		fmt.Println("[DEBUG] resolveCareerID URL:", baseURL+"/"+tag)
-	}
-
-	jar, _ := cookiejar.New(nil)
-
-	client := &http.Client{
-		Timeout: 15 * time.Second,
-		Jar:     jar,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
-
-	req, err := http.NewRequest("GET", baseURL+"/"+tag, nil)
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; ovrstat)")
-	req.Header.Set("Accept", "text/html")
-
-	for i := 0; i < 5; i++ {
-		resp, err := client.Do(req)
-		if err != nil {
-			return "", err
-		}
-
-		if Debug {
-			fmt.Println("[DEBUG] Redirect step", i)
-			fmt.Println("[DEBUG] Status:", resp.StatusCode)
-			fmt.Println("[DEBUG] Location:", resp.Header.Get("Location"))
-			fmt.Println("[DEBUG] Final URL:", resp.Request.URL.String())
-		}
-
-		// 🔥 FALL 1: Redirect mit Location
-		if resp.StatusCode >= 300 && resp.StatusCode < 400 {
-			loc := resp.Header.Get("Location")
-			if loc == "" {
-				resp.Body.Close()
-				return "", errors.New("redirect without location")
-			}
-
-			decodedLoc, _ := url.PathUnescape(loc)
-
-			if strings.Contains(decodedLoc, "|") {
-				id := strings.Trim(strings.TrimPrefix(decodedLoc, "/career/"), "/")
-				resp.Body.Close()
-				return id, nil
-			}
-
-			nextURL := loc
-			if strings.HasPrefix(loc, "/") {
-				nextURL = "https://overwatch.blizzard.com" + loc
-			}
-
-			resp.Body.Close()
-			req, _ = http.NewRequest("GET", nextURL, nil)
-			continue
-		}
-
-		// 🔥 FALL 2: Finaler 200-Request → URL auswerten
-		if resp.StatusCode == 200 {
-			finalPath := resp.Request.URL.Path
-			decodedPath, _ := url.PathUnescape(finalPath)
-
-			if strings.Contains(decodedPath, "|") {
-				id := strings.Trim(strings.TrimPrefix(decodedPath, "/en-us/career/"), "/")
-				resp.Body.Close()
-				return id, nil
-			}
-		}
-
-		resp.Body.Close()
-		break
-	}
-
-	if Debug {
-		fmt.Println("[DEBUG] resolveCareerID FAILED for tag:", tag)
-	}
-
-	return "", ErrPlayerNotFound
+	return defaultClient.UnlockInfoContext(context.Background(), unlockID)
 }
 
 // Stats retrieves player stats
 // Universal method if you don't need to differentiate it
 func Stats(platformKey, tag string) (*PlayerStats, error) {
-	// Do platform key mapping
-	switch platformKey {
-	case PlatformPC:
-		platformKey = "mouseKeyboard"
-	case PlatformConsole:
-		platformKey = "controller"
-	}
-
-	// Parse the API response first
-	var ps PlayerStats
+	return defaultClient.StatsContext(context.Background(), platformKey, tag)
+}
 
-	player, err := resolvePlayerByDoubleSearch(tag)
+// StatsContext retrieves the complete stats of a player. Upstream requests
+// are aborted once ctx is done.
+func (c *Client) StatsContext(ctx context.Context, platformKey, tag string) (*PlayerStats, error) {
+	page, err := c.CareerPageContext(ctx, tag)
 	if err != nil {
 		return nil, err
 	}
+	return page.Stats(platformKey)
+}
 
-	if !player.IsPublic {
-		ps.Private = true
-		return &ps, nil
-	}
+// Only Gets Profile Stats
+// This part is and will be mainly used in OWidget 2 Application
 
-	// Optional (wenn du es brauchst)
-	ps.NamecardImage = player.Namecard
+func ProfileStats(platformKey, tag string) (*PlayerStatsProfile, error) {
+	return defaultClient.ProfileStatsContext(context.Background(), platformKey, tag)
+}
 
-	// Create the profile url for scraping
-	// Change Minus in Name with '#' for correct searches
-	careerID, err := resolveCareerID(tag)
+// ProfileStatsContext retrieves the profile summary of a player. Upstream
+// requests are aborted once ctx is done.
+func (c *Client) ProfileStatsContext(ctx context.Context, platformKey, tag string) (*PlayerStatsProfile, error) {
+	page, err := c.CareerPageContext(ctx, tag)
 	if err != nil {
 		return nil, err
 	}
-
-	profileUrl := baseURL + "/" + careerID + "/"
-
-	if Debug {
-		fmt.Println("[DEBUG] Resolved CareerID:", careerID)
-		fmt.Println("[DEBUG] Profile URL:", profileUrl)
-	}
-
-	// Perform the stats request and decode the response
-	res, err := getOWClient().Get(profileUrl)
-
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to retrieve profile")
-	}
-	defer res.Body.Close()
-
-	// Parses the stats request into a goquery document
-	pd, err := goquery.NewDocumentFromReader(res.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to create goquery document")
-	}
-
-	// Checks if profile not found, site still returns 200 in this case
-	if pd.Find("[slot=heading]").First().Text() == "Page Not Found" {
-		return nil, ErrPlayerNotFound
-	}
-
-	ps.Name = pd.Find(".Profile-player--name").Text()
-
-	platforms := make(map[string]Platform)
-
-	pd.Find(".Profile-player--filters .Profile-player--filter").Each(func(i int, sel *goquery.Selection) {
-		id, _ := sel.Attr("id")
-
-		idMatch := filterRegexp.FindStringSubmatch(id)
-		if idMatch == nil {
-			return
-		}
-		id = idMatch[1]
-
-		viewID := "." + id + "-view"
-
-		// Using combined classes (.class.class2) we can filter out our views based on platform
-		rankWrapper := pd.Find(".Profile-playerSummary--rankWrapper" + viewID)
-
-		view := pd.Find(".Profile-view" + viewID)
-
-		if view.Length() == 0 {
-			return
-		}
-
-		platforms[id] = Platform{
-			Name:        sel.Text(),
-			RankWrapper: rankWrapper,
-			ProfileView: view,
-		}
-
-	})
-
-	platform, exists := platforms[platformKey]
-
-	if !exists {
-		return nil, ErrInvalidPlatform
-	}
-
-	// Scrapes all stats for the passed user and sets struct member data
-	parseGeneralInfo(platform, pd.Find(".Profile-masthead").First(), &ps)
-
-	parseDetailedStats(platform, ".quickPlay-view", &ps.QuickPlayStats.StatsCollection)
-	parseDetailedStats(platform, ".competitive-view", &ps.CompetitiveStats.StatsCollection)
-
-	competitiveSeason, _ := pd.Find("[data-latestherostatrankseasonow2]").Attr("data-latestherostatrankseasonow2")
-
-	if competitiveSeason != "" {
-		competitiveSeason, _ := strconv.Atoi(competitiveSeason)
-
-		ps.CompetitiveStats.Season = &competitiveSeason
-	}
-
-	addGameStats(&ps, &ps.QuickPlayStats.StatsCollection)
-	addGameStats(&ps, &ps.CompetitiveStats.StatsCollection)
-
-	return &ps, nil
+	return page.ProfileStats(platformKey)
 }
 
 func addGameStats(ps *PlayerStats, statsCollection *StatsCollection) {
@@ -432,226 +91,6 @@ func addGameStats(ps *PlayerStats, statsCollection *StatsCollection) {
 	}
 }
 
-// Only Gets Profile Stats
-// This part is and will be mainly used in OWidget 2 Application
-
-func ProfileStats(platformKey, tag string) (*PlayerStatsProfile, error) {
-	// Do platform key mapping
-	switch platformKey {
-	case PlatformPC:
-		platformKey = "mouseKeyboard"
-
-	case PlatformConsole:
-		platformKey = "controller"
-	}
-	// Parse the API response first
-	var ps PlayerStatsProfile
-
-	player, err := resolvePlayerByDoubleSearch(tag)
-	if err != nil {
-		return nil, err
-	}
-
-	if !player.IsPublic {
-		ps.Private = true
-		return &ps, nil
-	}
-
-	// Optional (wenn du es brauchst)
-	ps.NamecardImage = player.Namecard
-
-	// Create the profile url for scraping
-	careerID, err := resolveCareerID(tag)
-	if err != nil {
-		return nil, err
-	}
-
-	profileUrl := baseURL + "/" + careerID + "/"
-
-	if Debug {
-		fmt.Println("[DEBUG] Resolved CareerID:", careerID)
-		fmt.Println("[DEBUG] Profile URL:", profileUrl)
-	}
-
-	// Perform the stats request and decode the response
-	res, err := getOWClient().Get(profileUrl)
-
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to retrieve profile")
-	}
-	defer res.Body.Close()
-
-	// Parses the stats request into a goquery document
-	pd, err := goquery.NewDocumentFromReader(res.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to create goquery document")
-	}
-
-	// Checks if profile not found, site still returns 200 in this case
-	if pd.Find("[slot=heading]").First().Text() == "Page Not Found" {
-		return nil, ErrPlayerNotFound
-	}
-
-	ps.Name = pd.Find(".Profile-player--name").Text()
-
-	platforms := make(map[string]Platform)
-
-	pd.Find(".Profile-player--filters .Profile-player--filter").Each(func(i int, sel *goquery.Selection) {
-		id, _ := sel.Attr("id")
-
-		idMatch := filterRegexp.FindStringSubmatch(id)
-		if idMatch == nil {
-			return
-		}
-		id = idMatch[1]
-
-		viewID := "." + id + "-view"
-
-		// Using combined classes (.class.class2) we can filter out our views based on platform
-		rankWrapper := pd.Find(".Profile-playerSummary--rankWrapper" + viewID)
-
-		view := pd.Find(".Profile-view" + viewID)
-
-		if view.Length() == 0 {
-			return
-		}
-
-		platforms[id] = Platform{
-			Name:        sel.Text(),
-			RankWrapper: rankWrapper,
-			ProfileView: view,
-		}
-	})
-
-	platform, exists := platforms[platformKey]
-
-	if !exists {
-		return nil, ErrInvalidPlatform
-	}
-
-	// Scrapes all stats for the passed user and sets struct member data
-	parseGeneralInfoProfile(platform, pd.Find(".Profile-masthead").First(), &ps)
-
-	careerStats := parseCareerStats(platform.ProfileView.Find(".stats.competitive-view"))
-
-	if heroStats, ok := careerStats["allHeroes"]; ok {
-		if gamesPlayed, ok := heroStats.Game["gamesPlayed"].(int); ok {
-			ps.CompetitiveStats.GamesPlayed = gamesPlayed
-		}
-		if gamesWon, ok := heroStats.Game["gamesWon"].(int); ok {
-			ps.CompetitiveStats.GamesWon = gamesWon
-		}
-		if gamesLost, ok := heroStats.Game["gamesLost"].(int); ok {
-			ps.CompetitiveStats.GamesLost = gamesLost
-		}
-		if timePlayed, ok := heroStats.Game["timePlayed"].(string); ok {
-			ps.CompetitiveStats.TimePlayed = timePlayed
-		}
-	}
-	careerStatsQP := parseCareerStats(platform.ProfileView.Find(".stats.quickPlay-view"))
-	if seasonAttr, exists := pd.Find("[data-latestherostatrankseasonow2]").Attr("data-latestherostatrankseasonow2"); exists {
-		if seasonNumber, err := strconv.Atoi(seasonAttr); err == nil {
-			ps.CompetitiveStats.Season = &seasonNumber
-		}
-	}
-
-	if heroStats, ok := careerStatsQP["allHeroes"]; ok {
-		if gamesPlayed, ok := heroStats.Game["gamesPlayed"].(int); ok {
-			ps.QuickplayStats.GamesPlayed = gamesPlayed
-		}
-		if gamesWon, ok := heroStats.Game["gamesWon"].(int); ok {
-			ps.QuickplayStats.GamesWon = gamesWon
-		}
-		if gamesLost, ok := heroStats.Game["gamesLost"].(int); ok {
-			ps.QuickplayStats.GamesLost = gamesLost
-		}
-		if timePlayed, ok := heroStats.Game["timePlayed"].(string); ok {
-			ps.QuickplayStats.TimePlayed = timePlayed
-		}
-	}
-
-	mostPlayedHero := platform.ProfileView.
-		Find(".Profile-heroSummary--view.competitive-view").
-		Find(".Profile-progressBar-title").
-		First().
-		Text()
-
-	ps.CompetitiveStats.MostPlayedHero = strings.TrimSpace(mostPlayedHero)
-	ps.CompetitiveStats.MostPlayedHeroTimePlayed = platform.ProfileView.
-		Find(".Profile-heroSummary--view.competitive-view").
-		Find(".Profile-progressBar-description").
-		First().
-		Text()
-
-	if heroStats, ok := careerStats[cleanJSONKey(ps.CompetitiveStats.MostPlayedHero)]; ok {
-		if val, ok := heroStats.Game["gamesPlayed"]; ok {
-			if i, ok := val.(int); ok {
-				ps.CompetitiveStats.MostPlayedHeroGamesPlayed = i
-			}
-		}
-		if val, ok := heroStats.Game["winPercentage"]; ok {
-			if s, ok := val.(string); ok {
-				clean := strings.Replace(s, "%", "", -1)
-				if i, err := strconv.Atoi(clean); err == nil {
-					ps.CompetitiveStats.MostPlayedHeroWinPercentage = i
-				}
-			}
-		}
-	}
-
-	mostPlayedHeroQP := platform.ProfileView.
-		Find(".Profile-heroSummary--view.quickPlay-view").
-		Find(".Profile-progressBar-title").
-		First().
-		Text()
-
-	ps.QuickplayStats.MostPlayedHero = strings.TrimSpace(mostPlayedHeroQP)
-	ps.QuickplayStats.MostPlayedHeroTimePlayed = platform.ProfileView.
-		Find(".Profile-heroSummary--view.quickPlay-view").
-		Find(".Profile-progressBar-description").
-		First().
-		Text()
-
-	if heroStats, ok := careerStatsQP[cleanJSONKey(ps.QuickplayStats.MostPlayedHero)]; ok {
-		if val, ok := heroStats.Game["gamesPlayed"]; ok {
-			if i, ok := val.(int); ok {
-				ps.QuickplayStats.MostPlayedHeroGamesPlayed = i
-			}
-		}
-		if val, ok := heroStats.Game["winPercentage"]; ok {
-			if s, ok := val.(string); ok {
-				clean := strings.Replace(s, "%", "", -1)
-				if i, err := strconv.Atoi(clean); err == nil {
-					ps.QuickplayStats.MostPlayedHeroWinPercentage = i
-				}
-			}
-		}
-	}
-
-	return &ps, nil
-}
-
-func retrievePlayers(tag string) ([]Player, error) {
-	if strings.Contains(tag, "-") {
-		tag = strings.Replace(tag, "-", "#", -1)
-	}
-	// Perform api request
-	var platforms []Player
-
-	apires, err := http.Get(apiURL + tag)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to perform platform API request")
-	}
-
-	defer apires.Body.Close()
-
-	// Decode received JSON
-	if err := json.NewDecoder(apires.Body).Decode(&platforms); err != nil {
-		return nil, errors.Wrap(err, "Failed to decode platform API response")
-	}
-	return platforms, nil
-}
-
 var (
 	endorsementRegexp = regexp.MustCompile("/(\\d+)[.-]([a-z0-9]+)\\.svg")
 	rankRegexp        = regexp.MustCompile(`https://\S+Rank_([a-zA-Z]+)Tier[.-]([a-f0-9]+)\.png`)
@@ -685,18 +124,6 @@ func parseGeneralInfo(platform Platform, s *goquery.Selection, ps *PlayerStats)
 	}
 	ps.Title = s.Find(".Profile-player--title").Text()
 
-	// Try to get Namecard
-	if namecardAttr, exists := s.Attr("namecard-id"); exists {
-		ps.NamecardID = namecardAttr
-
-		// Hole die Info aus Blizzard Unlocks API
-		unlockInfo, err := GetUnlockInfo(namecardAttr)
-		if err == nil {
-			ps.NamecardTitle = unlockInfo.Name
-			ps.NamecardImage = unlockInfo.Icon
-		}
-	}
-
 	// Parse Endorsement Icon path (/svg?path=)
 	if strings.Index(ps.EndorsementIcon, "/svg") == 0 {
 		q, err := url.ParseQuery(ps.EndorsementIcon[strings.Index(ps.EndorsementIcon, "?")+1:])
@@ -776,18 +203,6 @@ func parseGeneralInfoProfile(platform Platform, s *goquery.Selection, ps *Player
 	}
 	ps.Title = s.Find(".Profile-player--title").Text()
 
-	// Try to get Namecard
-	if namecardAttr, exists := s.Attr("namecard-id"); exists {
-		ps.NamecardID = namecardAttr
-
-		// Hole die Info aus Blizzard Unlocks API
-		unlockInfo, err := GetUnlockInfo(namecardAttr)
-		if err == nil {
-			ps.NamecardTitle = unlockInfo.Name
-			ps.NamecardImage = unlockInfo.Icon
-		}
-	}
-
 	// Parse Endorsement Icon path (/svg?path=)
 	if strings.Index(ps.EndorsementIcon, "/svg") == 0 {
 		q, err := url.ParseQuery(ps.EndorsementIcon[strings.Index(ps.EndorsementIcon, "?")+1:])
@@ -835,89 +250,50 @@ func parseDetailedStats(platform Platform, playMode string, sc *StatsCollection)
 	for heroName, topStats := range sc.TopHeroes {
 		if careerStats, ok := sc.CareerStats[heroName]; ok {
 
-			// Helper closure to parse int from percent string or int value
-			parseVal := func(m map[string]interface{}, key string) int {
-				if v, ok := m[key]; ok {
-					switch val := v.(type) {
-					case int:
-						return val
-					case float64:
-						return int(val)
-					case string:
-						// Handle percentages "49%"
-						clean := strings.Replace(val, "%", "", -1)
-						clean = strings.Replace(clean, ",", "", -1)
-						if i, err := strconv.Atoi(clean); err == nil {
-							return i
-						}
-					}
-				}
-				return 0
-			}
-
-			// Helper for float parsing
-			parseFloat := func(m map[string]interface{}, key string) float64 {
-				if v, ok := m[key]; ok {
-					switch val := v.(type) {
-					case float64:
-						return val
-					case int:
-						return float64(val)
-					case string:
-						clean := strings.Replace(val, "%", "", -1)
-						clean = strings.Replace(clean, ",", "", -1)
-						if f, err := strconv.ParseFloat(clean, 64); err == nil {
-							return f
-						}
-					}
-				}
-				return 0
-			}
-
 			// Backfill logic
 			if topStats.WeaponAccuracy == 0 {
-				topStats.WeaponAccuracy = parseVal(careerStats.Combat, "weaponAccuracy")
+				topStats.WeaponAccuracy = statInt(careerStats.Combat, "weaponAccuracy")
 			}
 			if topStats.CriticalHitAccuracy == 0 {
-				topStats.CriticalHitAccuracy = parseVal(careerStats.Combat, "criticalHitAccuracy")
+				topStats.CriticalHitAccuracy = statInt(careerStats.Combat, "criticalHitAccuracy")
 				if topStats.CriticalHitAccuracy == 0 {
-					topStats.CriticalHitAccuracy = parseVal(careerStats.HeroSpecific, "criticalHitAccuracy") // Try hero specific
+					topStats.CriticalHitAccuracy = statInt(careerStats.HeroSpecific, "criticalHitAccuracy") // Try hero specific
 				}
 				if topStats.CriticalHitAccuracy == 0 {
-					topStats.CriticalHitAccuracy = parseVal(careerStats.HeroSpecific, "scopedAccuracy") // Ana specific example
+					topStats.CriticalHitAccuracy = statInt(careerStats.HeroSpecific, "scopedAccuracy") // Ana specific example
 				}
 			}
 			if topStats.ObjectiveKills == 0 {
-				topStats.ObjectiveKills = parseFloat(careerStats.Combat, "objectiveKills")
+				topStats.ObjectiveKills = statFloat(careerStats.Combat, "objectiveKills")
 			}
 
 			// New fields backfill
 			if topStats.GamesPlayed == 0 {
-				topStats.GamesPlayed = parseVal(careerStats.Game, "gamesPlayed")
+				topStats.GamesPlayed = statInt(careerStats.Game, "gamesPlayed")
 			}
 			if topStats.GamesLost == 0 {
-				topStats.GamesLost = parseVal(careerStats.Game, "gamesLost")
+				topStats.GamesLost = statInt(careerStats.Game, "gamesLost")
 			}
 			if topStats.WinPercentage == 0 {
-				topStats.WinPercentage = parseVal(careerStats.Game, "winPercentage")
+				topStats.WinPercentage = statInt(careerStats.Game, "winPercentage")
 			}
 			if topStats.ObjectiveKillsBest == 0 {
-				topStats.ObjectiveKillsBest = parseVal(careerStats.Best, "objectiveKillsMostInGame")
+				topStats.ObjectiveKillsBest = statInt(careerStats.Best, "objectiveKillsMostInGame")
 			}
 			if topStats.HealingDoneBest == 0 {
-				topStats.HealingDoneBest = parseVal(careerStats.Best, "healingDoneMostInGame")
+				topStats.HealingDoneBest = statInt(careerStats.Best, "healingDoneMostInGame")
 			}
 			if topStats.DamageDoneBest == 0 {
-				topStats.DamageDoneBest = parseVal(careerStats.Best, "allDamageDoneMostInGame")
+				topStats.DamageDoneBest = statInt(careerStats.Best, "allDamageDoneMostInGame")
 			}
 			if topStats.KillStreakBest == 0 {
-				topStats.KillStreakBest = parseVal(careerStats.Best, "killsStreakBest")
+				topStats.KillStreakBest = statInt(careerStats.Best, "killsStreakBest")
 			}
 			if topStats.MultiKillBest == 0 {
-				topStats.MultiKillBest = parseVal(careerStats.Best, "multikillsBest")
+				topStats.MultiKillBest = statInt(careerStats.Best, "multikillsBest")
 			}
 			if topStats.EliminationsPerLife == 0 {
-				topStats.EliminationsPerLife = parseFloat(careerStats.Average, "eliminationsPerLife")
+				topStats.EliminationsPerLife = statFloat(careerStats.Average, "eliminationsPerLife")
 			}
 		}
 	}
@@ -1,9 +1,12 @@
 package service
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/Domekologe/ow-api/cache"
+	"github.com/Domekologe/ow-api/ovrstat"
 	"github.com/labstack/echo/v4"
 )
 
@@ -35,15 +38,15 @@ func adminAuth(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
-// adminFlushCache clears the entire Redis cache
+// adminFlushCache clears the entire cache
 func adminFlushCache(c echo.Context) error {
-	if redisCache == nil {
+	if statsCache == nil {
 		return c.JSON(http.StatusServiceUnavailable, map[string]string{
-			"error": "Redis cache is not enabled",
+			"error": "Cache is not enabled",
 		})
 	}
 
-	if err := redisCache.FlushAll(); err != nil {
+	if err := statsCache.Flush(); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to flush cache: " + err.Error(),
 		})
@@ -54,47 +57,225 @@ func adminFlushCache(c echo.Context) error {
 	})
 }
 
-// adminTriggerScraper triggers an immediate scraper run
+// adminTriggerScraper asks the scraper for an immediate run over all cached
+// players, or the ones selected by the optional JSON body: a player
+// (platform and tag), a platform or a key pattern
 func adminTriggerScraper(c echo.Context) error {
-	if redisCache == nil {
+	if scraperRuns == nil {
 		return c.JSON(http.StatusServiceUnavailable, map[string]string{
-			"error": "Redis cache is not enabled",
+			"error": "Redis is not enabled",
 		})
 	}
 
-	// Get all cached keys
-	keys, err := redisCache.GetKeys("ow:stats:*")
+	var req struct {
+		Platform string `json:"platform"`
+		Tag      string `json:"tag"`
+		Pattern  string `json:"pattern"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	var target cache.RunTarget
+	switch {
+	case req.Tag != "":
+		id, err := ovrstat.ParsePlayerID(req.Platform, req.Tag)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid player: " + err.Error(),
+			})
+		}
+		target = cache.RunTarget{Platform: id.Platform, Tag: id.Key()}
+	case req.Pattern != "":
+		if !strings.HasPrefix(req.Pattern, "stats:") && !strings.HasPrefix(req.Pattern, "negative:") {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid pattern, it must start with stats: or negative:",
+			})
+		}
+		target = cache.RunTarget{Pattern: req.Pattern}
+	case req.Platform != "":
+		if req.Platform != ovrstat.PlatformPC && req.Platform != ovrstat.PlatformConsole {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid platform, use pc or console",
+			})
+		}
+		target = cache.RunTarget{Platform: req.Platform}
+	}
+
+	run, err := scraperRuns.Trigger(target)
+	if errors.Is(err, cache.ErrNoScraper) {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+			"error": "No scraper is running",
+		})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to get cache keys: " + err.Error(),
+			"error": "Failed to trigger scraper: " + err.Error(),
 		})
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"message":        "Scraper trigger received",
-		"note":           "This endpoint only triggers the scraper. For actual scraping, use the dedicated scraper service.",
-		"cached_players": len(keys),
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"message": "Scraper run triggered",
+		"run_id":  run.ID,
+		"target":  run.Target,
 	})
 }
 
+// adminScraperRun reports the progress of a scraper run
+func adminScraperRun(c echo.Context) error {
+	if scraperRuns == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+			"error": "Redis is not enabled",
+		})
+	}
+
+	run, err := scraperRuns.Get(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get scraper run: " + err.Error(),
+		})
+	}
+	if run == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Unknown scraper run",
+		})
+	}
+	return c.JSON(http.StatusOK, run)
+}
+
 // adminCacheStats returns cache statistics
 func adminCacheStats(c echo.Context) error {
-	if redisCache == nil {
+	if statsCache == nil {
 		return c.JSON(http.StatusServiceUnavailable, map[string]string{
-			"error": "Redis cache is not enabled",
+			"error": "Cache is not enabled",
 		})
 	}
 
-	keys, err := redisCache.GetKeys("ow:stats:*")
+	keys, err := collectKeys(cache.StatsKeyPattern)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to get cache stats: " + err.Error(),
 		})
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
+	stats, err := statsCache.Stats()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to get cache stats: " + err.Error(),
+		})
+	}
+
+	resp := map[string]interface{}{
 		"cached_players": len(keys),
 		"cache_keys":     keys,
+		"cache":          stats,
+	}
+	if refreshQueue != nil {
+		if queue, err := refreshQueue.Stats(); err == nil {
+			resp["refresh_queue"] = queue
+		}
+	}
+	if popularity != nil {
+		if n, err := popularity.Tracked(); err == nil {
+			resp["tracked_players"] = n
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// collectKeys gathers the cache keys matching pattern
+func collectKeys(pattern string) ([]string, error) {
+	keys := []string{}
+	err := statsCache.ScanKeys(pattern, func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	return keys, err
+}
+
+// negativeKinds reads the kinds of negative entries an admin request is
+// about from the ?kind= query parameter, all kinds when it is missing
+func negativeKinds(c echo.Context) ([]string, bool) {
+	switch kind := c.QueryParam("kind"); kind {
+	case "":
+		return []string{cache.NegativeNotFound, cache.NegativePrivate}, true
+	case cache.NegativeNotFound, cache.NegativePrivate:
+		return []string{kind}, true
+	}
+	return nil, false
+}
+
+// adminNegativeEntries lists the cached not-found players and private
+// profiles
+func adminNegativeEntries(c echo.Context) error {
+	if statsCache == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+			"error": "Cache is not enabled",
+		})
+	}
+
+	kinds, ok := negativeKinds(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid kind, use notfound or private",
+		})
+	}
+
+	entries := make(map[string][]string)
+	for _, kind := range kinds {
+		keys, err := collectKeys(cache.NegativeKeyPattern(kind))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to get cache keys: " + err.Error(),
+			})
+		}
+		entries[kind] = keys
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
+
+// adminPurgeNegative removes the cached not-found players and private
+// profiles, so they are looked up again on the next request
+func adminPurgeNegative(c echo.Context) error {
+	if statsCache == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+			"error": "Cache is not enabled",
+		})
+	}
+
+	kinds, ok := negativeKinds(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid kind, use notfound or private",
+		})
+	}
+
+	purged := 0
+	for _, kind := range kinds {
+		err := statsCache.ScanKeys(cache.NegativeKeyPattern(kind), func(key string) error {
+			pk, ok := cache.ParseKey(key)
+			if !ok {
+				return nil
+			}
+			if err := statsCache.Delete(pk.Platform, pk.Tag); err != nil {
+				return err
+			}
+			purged++
+			return nil
+		})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to purge cache: " + err.Error(),
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Negative cache entries purged",
+		"purged":  purged,
 	})
 }
 
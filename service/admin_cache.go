@@ -0,0 +1,215 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Domekologe/ow-api/cache"
+	"github.com/Domekologe/ow-api/ovrstat"
+	"github.com/labstack/echo/v4"
+)
+
+// adminRefreshTimeout is how long a refresh requested by an admin may take
+const adminRefreshTimeout = 30 * time.Second
+
+// Page sizes of the key listing
+const (
+	defaultKeysLimit = 50
+	maxKeysLimit     = 500
+)
+
+// cachedKey is one entry of the key listing
+type cachedKey struct {
+	Key      string `json:"key"`
+	Kind     string `json:"kind"`
+	Platform string `json:"platform"`
+	Tag      string `json:"tag"`
+}
+
+// adminPlayer parses the player of an admin request, writing the error
+// response itself when it fails
+func adminPlayer(c echo.Context) (ovrstat.PlayerID, bool, error) {
+	if statsCache == nil {
+		return ovrstat.PlayerID{}, false, c.JSON(http.StatusServiceUnavailable, map[string]string{
+			"error": "Cache is not enabled",
+		})
+	}
+
+	id, err := ovrstat.ParsePlayerID(c.Param("platform"), c.Param("tag"))
+	if err != nil {
+		return id, false, c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid player: " + err.Error(),
+		})
+	}
+	return id, true, nil
+}
+
+// inspectPlayer responds with everything cached for a player
+func inspectPlayer(c echo.Context, id ovrstat.PlayerID) error {
+	entries, err := statsCache.Inspect(id.Platform, id.Key())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to inspect cache: " + err.Error(),
+		})
+	}
+	if entries.Empty() {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Player is not cached",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"player":   id.String(),
+		"platform": id.Platform,
+		"tag":      id.Key(),
+		"entries":  entries,
+	})
+}
+
+// adminInspectPlayer shows the cache entries of a player with their age,
+// TTL, size and metadata
+func adminInspectPlayer(c echo.Context) error {
+	id, ok, err := adminPlayer(c)
+	if !ok {
+		return err
+	}
+	return inspectPlayer(c, id)
+}
+
+// adminDeletePlayer removes the stats, profile and negative entries of a
+// player
+func adminDeletePlayer(c echo.Context) error {
+	id, ok, err := adminPlayer(c)
+	if !ok {
+		return err
+	}
+
+	if err := statsCache.Delete(id.Platform, id.Key()); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete player: " + err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Player removed from cache",
+		"player":  id.String(),
+	})
+}
+
+// adminRefreshPlayer scrapes a player right away and responds with the new
+// cache entries
+func adminRefreshPlayer(c echo.Context) error {
+	id, ok, err := adminPlayer(c)
+	if !ok {
+		return err
+	}
+
+	res, _, err := careerWithTimeout(id, adminRefreshTimeout)
+	switch {
+	case errors.Is(err, ovrstat.ErrPlayerNotFound):
+		rememberNotFound(id)
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Player not found",
+		})
+	case errors.Is(err, errRequestTimeout):
+		return c.JSON(http.StatusGatewayTimeout, map[string]string{
+			"error": "Refresh timed out",
+		})
+	case err != nil:
+		return c.JSON(http.StatusBadGateway, map[string]string{
+			"error": "Failed to refresh player: " + err.Error(),
+		})
+	}
+
+	if err := cacheCareer(id, res); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to cache player: " + err.Error(),
+		})
+	}
+	return inspectPlayer(c, id)
+}
+
+// adminListKeys lists the cached stats and negative keys, filtered by the
+// ?q= (part of the tag), ?kind= and ?platform= query parameters and paged by
+// ?offset= and ?limit=
+func adminListKeys(c echo.Context) error {
+	if statsCache == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+			"error": "Cache is not enabled",
+		})
+	}
+
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil || offset < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid offset",
+		})
+	}
+	limit, err := queryInt(c, "limit", defaultKeysLimit)
+	if err != nil || limit < 1 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid limit",
+		})
+	}
+	if limit > maxKeysLimit {
+		limit = maxKeysLimit
+	}
+
+	q := strings.ToLower(c.QueryParam("q"))
+	kind := c.QueryParam("kind")
+	platform := c.QueryParam("platform")
+
+	keys := []cachedKey{}
+	collect := func(key string) error {
+		pk, ok := cache.ParseKey(key)
+		if !ok {
+			return nil
+		}
+		if (kind != "" && pk.Kind != kind) || (platform != "" && pk.Platform != platform) {
+			return nil
+		}
+		if q != "" && !strings.Contains(strings.ToLower(pk.Tag), q) {
+			return nil
+		}
+		keys = append(keys, cachedKey{Key: key, Kind: pk.Kind, Platform: pk.Platform, Tag: pk.Tag})
+		return nil
+	}
+	for _, pattern := range []string{cache.StatsKeyPattern, cache.NegativeKeyPattern("")} {
+		if err := statsCache.ScanKeys(pattern, collect); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to get cache keys: " + err.Error(),
+			})
+		}
+	}
+
+	// SCAN returns keys in no particular order, sort them for stable pages
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	total := len(keys)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"keys":   keys[offset:end],
+		"total":  total,
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+// queryInt reads an integer query parameter, def when it is missing
+func queryInt(c echo.Context, name string, def int) (int, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
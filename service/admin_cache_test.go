@@ -0,0 +1,64 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Domekologe/ow-api/cache"
+	"github.com/Domekologe/ow-api/ovrstat"
+	"github.com/labstack/echo/v4"
+)
+
+func TestAdminCachePlayer(t *testing.T) {
+	prevCache, prevPassword := statsCache, adminPassword
+	m := cache.NewMemoryStore(10, time.Hour)
+	statsCache = m
+	adminPassword = "secret"
+	t.Cleanup(func() { statsCache, adminPassword = prevCache, prevPassword })
+
+	m.Set("pc", "foo-1234", &ovrstat.PlayerStats{Name: "Foo"})
+	m.Set("pc", "bar-5678", &ovrstat.PlayerStats{Name: "Bar"})
+
+	e := echo.New()
+	admin := e.Group("/admin", adminAuth)
+	admin.GET("/cache/keys", adminListKeys)
+	admin.GET("/cache/:platform/:tag", adminInspectPlayer)
+	admin.DELETE("/cache/:platform/:tag", adminDeletePlayer)
+
+	do := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/admin/cache/keys?q=FOO&limit=1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("keys status = %d; want 200: %s", rec.Code, rec.Body)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"key":"stats:pc:foo-1234"`) || !strings.Contains(body, `"total":1`) {
+		t.Errorf("keys body = %s; want only foo-1234", body)
+	}
+
+	rec = do(http.MethodGet, "/admin/cache/pc/Foo-1234")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("inspect status = %d; want 200: %s", rec.Code, rec.Body)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"key":"stats:pc:foo-1234"`) || !strings.Contains(body, `"profile":null`) {
+		t.Errorf("inspect body = %s; want the complete entry only", body)
+	}
+
+	if rec = do(http.MethodDelete, "/admin/cache/pc/Foo-1234"); rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d; want 200: %s", rec.Code, rec.Body)
+	}
+	if rec = do(http.MethodGet, "/admin/cache/pc/Foo-1234"); rec.Code != http.StatusNotFound {
+		t.Errorf("inspect after delete status = %d; want 404", rec.Code)
+	}
+	if stats, _ := m.Get("pc", "bar-5678"); stats == nil {
+		t.Error("other player was deleted too")
+	}
+}
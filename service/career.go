@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Domekologe/ow-api/cache"
+	"github.com/Domekologe/ow-api/ovrstat"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// cachedCareerID returns the career ID the tag of a player resolved to last,
+// or "" when it isn't known
+func cachedCareerID(id ovrstat.PlayerID) string {
+	if statsCache == nil {
+		return ""
+	}
+	careerID, err := statsCache.CareerID(id.Key())
+	if err != nil && !errors.Is(err, cache.ErrUnavailable) {
+		log.Printf("Failed to get career ID of %s: %v", id, err)
+	}
+	return careerID
+}
+
+// rememberCareer caches the career ID the tag of a player resolved to and
+// the name its page shows
+func rememberCareer(id ovrstat.PlayerID, page *ovrstat.CareerPage) {
+	if statsCache == nil || page.CareerID == "" {
+		return
+	}
+	if err := statsCache.SetCareerID(id.Key(), page.CareerID); err != nil {
+		log.Printf("Failed to cache career ID of %s: %v", id, err)
+	}
+	noteCareerName(page.CareerID, page.Name())
+}
+
+// noteCareerName records the name a career page shows, and logs when it
+// differs from the one it showed before
+func noteCareerName(careerID, name string) {
+	if statsCache == nil || name == "" {
+		return
+	}
+	career, err := statsCache.Career(careerID)
+	if err != nil {
+		log.Printf("Failed to get career %s: %v", careerID, err)
+		return
+	}
+	if career == nil {
+		career = &cache.Career{ID: careerID}
+	}
+	from := career.Name
+	if strings.EqualFold(from, name) {
+		return
+	}
+	if career.SeenName(name) {
+		log.Printf("Career %s was renamed from %s to %s", careerID, from, name)
+	}
+	if err := statsCache.SetCareer(career); err != nil {
+		log.Printf("Failed to cache career %s: %v", careerID, err)
+	}
+}
+
+// careerParams parses the career ID and platform of a request to the career
+// routes
+func careerParams(c echo.Context) (careerID, platform string, err error) {
+	careerID, err = ovrstat.ParseCareerID(c.Param("careerId"))
+	if err != nil {
+		return "", "", newErr(http.StatusBadRequest, err)
+	}
+	platform = strings.ToLower(c.Param("platform"))
+	if platform != ovrstat.PlatformPC && platform != ovrstat.PlatformConsole {
+		return "", "", newErr(http.StatusBadRequest, ovrstat.ErrInvalidPlatform)
+	}
+	return careerID, platform, nil
+}
+
+// careerPlayer maps a career ID back to the player whose tag resolves to it,
+// so lookups by career ID share the cache of lookups by tag. The tag is the
+// name the career page showed last with the number of the career ID, and it
+// must still resolve to that career ID.
+func careerPlayer(careerID, platform string) (ovrstat.PlayerID, bool) {
+	if statsCache == nil {
+		return ovrstat.PlayerID{}, false
+	}
+	tag, _, _ := strings.Cut(careerID, "|")
+	career, err := statsCache.Career(careerID)
+	if err != nil && !errors.Is(err, cache.ErrUnavailable) {
+		log.Printf("Failed to get career %s: %v", careerID, err)
+	}
+	if i := strings.LastIndex(tag, "-"); career != nil && career.Name != "" && i != -1 {
+		tag = career.Name + tag[i:]
+	}
+
+	id, err := ovrstat.ParsePlayerID(platform, tag)
+	if err != nil || cachedCareerID(id) != careerID {
+		return ovrstat.PlayerID{}, false
+	}
+	return id, true
+}
+
+// careerLookup fetches the career page of a career ID live, skipping the
+// resolution of a BattleTag. It is used for career IDs no cached tag
+// resolves to, their stats aren't cached as there is no tag to cache them
+// under. Returned errors are ready to be sent to the client.
+func careerLookup(c echo.Context, careerID, platform string) (*careerResult, bool, error) {
+	log.Printf("Request: Career %s/%s, from: %s", platform, careerID, c.RealIP())
+
+	key := "career:" + platform + ":" + careerID
+	res, shared, err := lookupWithTimeout(key, liveTimeout(), func(ctx context.Context) (*careerResult, error) {
+		page, err := ovrClient.CareerPageByIDContext(ctx, careerID, "")
+		if err != nil {
+			return nil, err
+		}
+		noteCareerName(careerID, page.Name())
+		return careerStats(page, platform)
+	})
+	setCoalesced(c, shared)
+	if err != nil {
+		log.Printf("Response: Career %s/%s - Error: %v", platform, careerID, err)
+		if errors.Is(err, errRequestTimeout) {
+			return nil, shared, codedErr(http.StatusGatewayTimeout, "timeout", "Request timeout")
+		}
+		return nil, shared, newErr(http.StatusInternalServerError,
+			errors.Wrap(err, "Failed to retrieve player stats"))
+	}
+	log.Printf("Response: Career %s/%s - Player found", platform, careerID)
+	return res, shared, nil
+}
+
+// statsCareerComplete serves the complete stats of a player by career ID
+func statsCareerComplete(c echo.Context) error {
+	careerID, platform, err := careerParams(c)
+	if err != nil {
+		return err
+	}
+	schema, err := responseSchema(c)
+	if err != nil {
+		return err
+	}
+	if id, ok := careerPlayer(careerID, platform); ok {
+		return serveComplete(c, id, schema)
+	}
+
+	res, shared, err := careerLookup(c, careerID, platform)
+	if err != nil {
+		return err
+	}
+
+	// The result may be shared with coalesced requests, season resets are
+	// applied to a copy
+	statsCopy := *res.Stats
+	stats := &statsCopy
+	applySeasonResetsIfConfigured(stats)
+	return sendStats(c, liveLookup(shared), withSchema(stats, schema))
+}
+
+// statsCareerProfile serves the profile stats of a player by career ID
+func statsCareerProfile(c echo.Context) error {
+	careerID, platform, err := careerParams(c)
+	if err != nil {
+		return err
+	}
+	if id, ok := careerPlayer(careerID, platform); ok {
+		return serveProfile(c, id)
+	}
+
+	res, shared, err := careerLookup(c, careerID, platform)
+	if err != nil {
+		return err
+	}
+
+	statsCopy := *res.Profile
+	stats := &statsCopy
+	applySeasonResetsProfileIfConfigured(stats)
+	return sendStats(c, liveLookup(shared), stats)
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/Domekologe/ow-api/ovrstat"
+	"github.com/labstack/echo/v4"
+)
+
+// Kinds of in-flight lookups. The complete, profile and hero endpoints all
+// share the live kind, one career page answers every one of them.
+const (
+	flightLive    = "live"
+	flightRefresh = "refresh"
+)
+
+// headerCoalesced tells whether a response was shared with concurrent
+// requests for the same player
+const headerCoalesced = "X-Request-Coalesced"
+
+// flight is one lookup in progress that concurrent callers share
+type flight struct {
+	done chan struct{}
+	res  *careerResult
+	err  error
+}
+
+// flightGroup deduplicates concurrent lookups of the same player
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flights holds the lookups currently in progress
+var flights = &flightGroup{flights: make(map[string]*flight)}
+
+// flightKey identifies the lookups of one player, however their tag was
+// spelled
+func flightKey(kind string, id ovrstat.PlayerID) string {
+	return kind + ":" + id.Platform + ":" + id.Key()
+}
+
+// join returns the lookup in progress for key. If there is none, a new one is
+// registered and leader is true: the caller must run it and call finish.
+func (g *flightGroup) join(key string) (f *flight, leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if f, ok := g.flights[key]; ok {
+		return f, false
+	}
+	f = &flight{done: make(chan struct{})}
+	g.flights[key] = f
+	return f, true
+}
+
+// finish publishes the result of a lookup to everyone waiting on it
+func (g *flightGroup) finish(key string, f *flight, res *careerResult, err error) {
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+
+	f.res, f.err = res, err
+	close(f.done)
+}
+
+// setCoalesced adds the headerCoalesced header to the response
+func setCoalesced(c echo.Context, shared bool) {
+	if shared {
+		c.Response().Header().Set(headerCoalesced, "true")
+	} else {
+		c.Response().Header().Set(headerCoalesced, "false")
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Domekologe/ow-api/ovrstat"
+)
+
+// newFakeBlizzard serves a minimal career page for Foo-1234 and counts how
+// often it was requested
+func newFakeBlizzard(t *testing.T, delay time.Duration) (*httptest.Server, *int32) {
+	t.Helper()
+	var hits int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/en-us/career/Foo-1234", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/en-us/career/Foo-1234%7Cabc123/", http.StatusFound)
+	})
+	mux.HandleFunc("/en-us/career/Foo-1234|abc123/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		time.Sleep(delay)
+		w.Write([]byte(`<html><body><div class="Profile-masthead">
+			<h1 class="Profile-player--name">Foo</h1>
+			<div class="Profile-player--filters"><div class="Profile-player--filter" id="mouseKeyboardFilter">PC</div></div>
+			</div><div class="Profile-view mouseKeyboard-view"></div></body></html>`))
+	})
+	mux.HandleFunc("/en-us/search/account-by-name/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"battleTag":"Foo#1234","isPublic":true}]`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func TestCareerWithTimeoutCoalesces(t *testing.T) {
+	srv, hits := newFakeBlizzard(t, 100*time.Millisecond)
+	prev := ovrClient
+	ovrClient = ovrstat.NewClient(ovrstat.WithBaseURL(srv.URL))
+	t.Cleanup(func() { ovrClient = prev })
+
+	const n = 10
+	var wg sync.WaitGroup
+	var sharedCount int32
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Both tag spellings are the same player
+			tag := "Foo-1234"
+			if i%2 == 1 {
+				tag = "Foo#1234"
+			}
+			id, err := ovrstat.ParsePlayerID("pc", tag)
+			if err != nil {
+				t.Errorf("ParsePlayerID: %v", err)
+				return
+			}
+			res, shared, err := careerWithTimeout(id, 5*time.Second)
+			if err != nil {
+				t.Errorf("careerWithTimeout: %v", err)
+				return
+			}
+			if res.Stats.Name != "Foo" {
+				t.Errorf("Name = %q; want Foo", res.Stats.Name)
+			}
+			if shared {
+				atomic.AddInt32(&sharedCount, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Errorf("career page fetched %d times; want 1", got)
+	}
+	if sharedCount != n-1 {
+		t.Errorf("%d responses shared; want %d", sharedCount, n-1)
+	}
+}
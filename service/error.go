@@ -1,17 +1,73 @@
 package service
 
-import "github.com/labstack/echo/v4"
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Domekologe/ow-api/ovrstat"
+	"github.com/labstack/echo/v4"
+)
+
+// errRequestTimeout is returned when a live lookup didn't finish in time
+var errRequestTimeout = errors.New("request timeout")
+
+// errorBody is the JSON body of every error response. Code is stable and
+// meant for programs, Message for humans.
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// knownErrors maps the errors of a lookup to their status and code. The first
+// match wins.
+var knownErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{ovrstat.ErrPlayerNotFound, http.StatusNotFound, "player_not_found"},
+	{ovrstat.ErrInvalidPlatform, http.StatusUnprocessableEntity, "invalid_platform"},
+	{ovrstat.ErrInvalidTag, http.StatusBadRequest, "invalid_tag"},
+	{ovrstat.ErrInvalidCareerID, http.StatusBadRequest, "invalid_career_id"},
+	{ovrstat.ErrRateLimitedLocally, http.StatusTooManyRequests, "rate_limited"},
+	{ovrstat.ErrUpstreamRateLimited, http.StatusTooManyRequests, "upstream_rate_limited"},
+	{ovrstat.ErrUpstreamUnavailable, http.StatusServiceUnavailable, "upstream_unavailable"},
+	{ovrstat.ErrProfileLayoutChanged, http.StatusBadGateway, "profile_layout_changed"},
+	{ovrstat.ErrParseFailure, http.StatusBadGateway, "parse_failure"},
+}
 
 // newErr creates and returns a new echo HTTPError with the passed status code
-// and optional message. Message expected to be of type string or error
+// and optional message. Message expected to be of type string or error. Known
+// lookup errors override the status code with a more precise one.
 func newErr(code int, message ...interface{}) error {
+	body := errorBody{Message: "An error has occurred"}
 	if len(message) > 0 {
 		switch v := message[0].(type) {
 		case error:
-			return echo.NewHTTPError(code, v.Error())
+			body.Message = v.Error()
+			for _, known := range knownErrors {
+				if errors.Is(v, known.err) {
+					code, body.Code = known.status, known.code
+					break
+				}
+			}
 		case string:
-			return echo.NewHTTPError(code, v)
+			body.Message = v
 		}
 	}
-	return echo.NewHTTPError(code, "An error has occurred")
+	if body.Code == "" {
+		body.Code = statusCode(code)
+	}
+	return echo.NewHTTPError(code, body)
+}
+
+// codedErr creates an echo HTTPError with an explicit code
+func codedErr(status int, code, message string) error {
+	return echo.NewHTTPError(status, errorBody{Code: code, Message: message})
+}
+
+// statusCode turns a HTTP status into a code, e.g. 504 into "gateway_timeout"
+func statusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
 }
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/Domekologe/ow-api/ovrstat"
+	"github.com/labstack/echo/v4"
+)
+
+// statsHero serves the quickplay and competitive stats of a single hero. It
+// is built from the complete stats, by default served from the cache.
+func statsHero(c echo.Context) error {
+	id, err := playerID(c)
+	if err != nil {
+		return err
+	}
+	hero := c.Param("hero")
+	clientIP := c.RealIP()
+
+	schema, err := responseSchema(c)
+	if err != nil {
+		return err
+	}
+
+	// Unknown heroes are turned away before the player is looked up
+	if _, ok := ovrstat.HeroKey(hero); !ok {
+		return heroNotFound(c)
+	}
+
+	// Log request
+	logRequest(id, clientIP)
+	recordAccess(id)
+
+	stats, lk, err := completeStats(id, serving.hero)
+	setCoalesced(c, lk.shared)
+	if err != nil {
+		return err
+	}
+
+	if stats.Private {
+		return codedErr(http.StatusForbidden, "profile_private", "Profile is private")
+	}
+
+	hs, ok := stats.Hero(hero)
+	if !ok {
+		return heroNotFound(c)
+	}
+
+	if schema == ovrstat.SchemaV2 {
+		hs.QuickPlay.CareerStats = hs.QuickPlay.CareerStats.Normalized()
+		hs.Competitive.CareerStats = hs.Competitive.CareerStats.Normalized()
+	}
+	return sendStats(c, lk, hs)
+}
+
+// heroNotFound answers a 404 listing the keys of every known hero
+func heroNotFound(c echo.Context) error {
+	return c.JSON(http.StatusNotFound, map[string]interface{}{
+		"code":    "hero_not_found",
+		"message": "Hero not found!",
+		"heroes":  ovrstat.KnownHeroes(),
+	})
+}
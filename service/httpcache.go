@@ -0,0 +1,108 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Values of the X-Data-Source header
+const (
+	sourceLive  = "live"
+	sourceCache = "cache"
+	sourceStale = "stale"
+)
+
+// lookup describes where the data of a response came from
+type lookup struct {
+	source    string
+	fetchedAt time.Time
+	shared    bool
+}
+
+// liveLookup describes data that was just scraped
+func liveLookup(shared bool) lookup {
+	return lookup{source: sourceLive, fetchedAt: time.Now(), shared: shared}
+}
+
+// cachedLookup describes data read from the cache, which is stale once it is
+// older than the serving freshness
+func cachedLookup(fetchedAt time.Time, shared bool) lookup {
+	lk := lookup{source: sourceCache, fetchedAt: fetchedAt, shared: shared}
+	if time.Since(fetchedAt) > serving.freshFor {
+		lk.source = sourceStale
+	}
+	return lk
+}
+
+// sendStats writes v as JSON together with the HTTP caching headers of lk. It
+// answers with 304 Not Modified when the client already has this version.
+func sendStats(c echo.Context, lk lookup, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	age := time.Since(lk.fetchedAt)
+	if age < 0 {
+		age = 0
+	}
+
+	h := c.Response().Header()
+	h.Set("ETag", etag)
+	h.Set("Last-Modified", lk.fetchedAt.UTC().Format(http.TimeFormat))
+	h.Set("Age", strconv.Itoa(int(age.Seconds())))
+	h.Set("X-Data-Source", lk.source)
+	h.Set("Cache-Control", cacheControl(age))
+
+	if notModified(c.Request(), etag, lk.fetchedAt) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.JSONBlob(http.StatusOK, body)
+}
+
+// cacheControl lets clients keep a response for as long as it would stay in
+// our cache. Without a cache they have to revalidate every time.
+func cacheControl(age time.Duration) string {
+	if statsCache == nil {
+		return "no-cache"
+	}
+	maxAge := statsCache.TTL() - age
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	return "public, max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+}
+
+// notModified evaluates the conditional headers of req. If-None-Match takes
+// precedence over If-Modified-Since, as RFC 9110 requires.
+func notModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			// Weak comparison, the gzip middleware changes the bytes on the wire
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		// Last-Modified only has second precision
+		return !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
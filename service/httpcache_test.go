@@ -0,0 +1,63 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestSendStatsConditional(t *testing.T) {
+	e := echo.New()
+	fetchedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	lk := lookup{source: sourceStale, fetchedAt: fetchedAt}
+	body := map[string]string{"name": "Foo"}
+
+	serve := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		if err := sendStats(e.NewContext(req, rec), lk, body); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	rec := serve("", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; want 200", rec.Code)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if got := rec.Header().Get("Last-Modified"); got != fetchedAt.UTC().Format(http.TimeFormat) {
+		t.Errorf("Last-Modified = %q", got)
+	}
+	if got := rec.Header().Get("X-Data-Source"); got != sourceStale {
+		t.Errorf("X-Data-Source = %q; want %q", got, sourceStale)
+	}
+	if got := rec.Header().Get("Age"); got != "3600" {
+		t.Errorf("Age = %q; want 3600", got)
+	}
+
+	tests := []struct {
+		header, value string
+		want          int
+	}{
+		{"If-None-Match", etag, http.StatusNotModified},
+		{"If-None-Match", `"other", ` + etag, http.StatusNotModified},
+		{"If-None-Match", `"other"`, http.StatusOK},
+		{"If-Modified-Since", fetchedAt.UTC().Format(http.TimeFormat), http.StatusNotModified},
+		{"If-Modified-Since", fetchedAt.Add(-time.Minute).UTC().Format(http.TimeFormat), http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := serve(tt.header, tt.value); rec.Code != tt.want {
+			t.Errorf("%s: %s = %d; want %d", tt.header, tt.value, rec.Code, tt.want)
+		}
+	}
+}
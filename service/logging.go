@@ -1,9 +1,14 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"time"
 
+	"github.com/Domekologe/ow-api/cache"
 	"github.com/Domekologe/ow-api/ovrstat"
+	"github.com/pkg/errors"
 )
 
 var debugLogging bool
@@ -14,75 +19,141 @@ func SetDebugLogging(enabled bool) {
 }
 
 // logRequest logs an incoming request in a clean format
-func logRequest(platform, tag, ip string) {
-	log.Printf("Request: Player %s/%s, from: %s", platform, tag, ip)
+func logRequest(id ovrstat.PlayerID, ip string) {
+	log.Printf("Request: Player %s, from: %s", id, ip)
 }
 
 // logResponse logs the response status
-func logResponse(platform, tag string, status string) {
-	log.Printf("Response: %s/%s - %s", platform, tag, status)
+func logResponse(id ovrstat.PlayerID, status string) {
+	log.Printf("Response: %s - %s", id, status)
 }
 
-// triggerScraperUpdate adds a player to the scraper queue (async)
-func triggerScraperUpdate(platform, tag string) {
-	if redisCache == nil {
+// refreshTimeout bounds one background refresh, so a hanging scrape doesn't
+// hold a refresh worker forever
+const refreshTimeout = 30 * time.Second
+
+// refreshQueue holds the players waiting for a background refresh
+var refreshQueue cache.RefreshQueue
+
+// scraperRuns records the runs of the scraper, nil without Redis. The jobs of
+// a run may be refreshed by the API, which reports them here.
+var scraperRuns *cache.ScraperRuns
+
+// popularity records the lookups of players for the scraper, nil without
+// Redis
+var popularity *cache.Popularity
+
+// accessFlushInterval is how often recorded lookups are written to Redis
+const accessFlushInterval = time.Second
+
+// extendOnFailure is how long the stats of a player are kept at least when
+// their refresh fails, 0 lets them expire
+var extendOnFailure time.Duration
+
+// keepStats keeps the last good stats of a player after a failed refresh,
+// e.g. through a Blizzard outage
+func keepStats(id ovrstat.PlayerID) {
+	e, ok := statsCache.(cache.Extender)
+	if !ok || extendOnFailure <= 0 {
 		return
 	}
+	if err := e.Extend(id.Platform, id.Key(), extendOnFailure); err != nil {
+		log.Printf("Failed to keep the entries of %s: %v", id, err)
+	}
+}
 
-	go func() {
-		// Recover from any panic so a malformed profile doesn't crash the process.
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("Background scraper panic for %s/%s: %v", platform, tag, r)
-			}
-		}()
-
-		// Fetch fresh stats in background
-		stats, err := ovrstat.Stats(platform, tag)
-		if err != nil {
-			log.Printf("Background scraper failed for %s/%s: %v", platform, tag, err)
-			return
-		}
+// recordAccess counts a lookup of a player
+func recordAccess(id ovrstat.PlayerID) {
+	if popularity != nil {
+		popularity.Record(id.Platform, id.Key())
+	}
+}
 
-		// Update cache
-		if err := redisCache.Set(platform, tag, stats); err != nil {
-			log.Printf("Failed to update cache for %s/%s: %v", platform, tag, err)
-			return
-		}
+// triggerScraperUpdate adds a player to the refresh queue. Both the complete
+// and the profile entry are refreshed from one career page.
+func triggerScraperUpdate(id ovrstat.PlayerID) {
+	if statsCache == nil || refreshQueue == nil {
+		return
+	}
 
-		log.Printf("Background scraper updated %s/%s", platform, tag)
-	}()
+	job := cache.RefreshJob{Platform: id.Platform, Tag: id.Key(), Priority: cache.PriorityOnDemand}
+	_, dropped, err := refreshQueue.Push(job)
+	if err != nil {
+		log.Printf("Failed to queue refresh of %s: %v", id, err)
+	}
+	if dropped != nil {
+		reportRefresh(*dropped, cache.ErrQueueFull)
+	}
 }
 
-// triggerScraperUpdateProfile adds a player profile to the scraper queue (async)
-func triggerScraperUpdateProfile(platform, tag string) {
-	if redisCache == nil {
+// reportRefresh records the outcome of a job in its scraper run, if any
+func reportRefresh(job cache.RefreshJob, err error) {
+	if job.Run == "" || scraperRuns == nil {
 		return
 	}
+	if err := scraperRuns.Done(job.Run, err); err != nil {
+		log.Printf("Failed to report refresh of %s:%s: %v", job.Platform, job.Tag, err)
+	}
+}
 
-	go func() {
-		// Recover from any panic so a malformed profile doesn't crash the process.
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("Background scraper (profile) panic for %s/%s: %v", platform, tag, r)
-			}
-		}()
-
-		// Fetch fresh profile stats in background
-		stats, err := ovrstat.ProfileStats(platform, tag)
-		if err != nil {
-			log.Printf("Background scraper (profile) failed for %s/%s: %v", platform, tag, err)
-			return
+// refreshPlayer runs a queued refresh
+func refreshPlayer(ctx context.Context, job cache.RefreshJob) {
+	// The scraper run of the job gave up on it
+	if scraperRuns != nil && scraperRuns.Expired(job.Run) {
+		reportRefresh(job, cache.ErrRunExpired)
+		return
+	}
+
+	id, err := ovrstat.ParsePlayerID(job.Platform, job.Tag)
+	if err != nil {
+		log.Printf("Invalid player in refresh job %s:%s: %v", job.Platform, job.Tag, err)
+		reportRefresh(job, err)
+		return
+	}
+
+	// A refresh of this player is already running, it updates the cache for
+	// everyone
+	key := flightKey(flightRefresh, id)
+	f, leader := flights.join(key)
+	if !leader {
+		<-f.done
+		reportRefresh(job, f.err)
+		return
+	}
+
+	var res *careerResult
+	defer func() { reportRefresh(job, err) }()
+	// Recover from any panic so a malformed profile doesn't crash the process.
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Background scraper panic for %s: %v", id, r)
+			res, err = nil, fmt.Errorf("panic during background scrape: %v", r)
 		}
+		flights.finish(key, f, res, err)
+	}()
 
-		// Update cache
-		if err := redisCache.SetProfile(platform, tag, stats); err != nil {
-			log.Printf("Failed to update profile cache for %s/%s: %v", platform, tag, err)
-			return
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+
+	// Fetch fresh stats in background
+	res, err = fetchCareer(ctx, id)
+	if err != nil {
+		log.Printf("Background scraper failed for %s: %v", id, err)
+		if errors.Is(err, ovrstat.ErrPlayerNotFound) {
+			rememberNotFound(id)
+		} else {
+			keepStats(id)
 		}
+		return
+	}
 
-		log.Printf("Background scraper updated profile %s/%s", platform, tag)
-	}()
+	// Update cache
+	if err = cacheCareer(id, res); err != nil {
+		log.Printf("Failed to update cache for %s: %v", id, err)
+		return
+	}
+
+	log.Printf("Background scraper updated %s (%s)", id, job.Priority)
 }
 
 // getClientIP extracts the real client IP from the request
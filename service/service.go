@@ -1,13 +1,16 @@
 package service
 
 import (
+	"context"
 	"embed"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 
+	"github.com/Domekologe/ow-api/cache"
 	"github.com/Domekologe/ow-api/config"
+	"github.com/Domekologe/ow-api/ovrstat"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	glog "github.com/labstack/gommon/log"
@@ -26,25 +29,85 @@ func Start(port string) {
 	// Load configuration
 	cfg := config.Load()
 
-	// Initialize Redis if enabled
+	// Without Redis, or while it is down, keep players in memory so timeout
+	// fallback and background refresh still work
+	var memory *cache.MemoryStore
+	if cfg.Memory.Enabled {
+		memory = cache.NewMemoryStore(cfg.Memory.MaxEntries, cfg.GetCacheTTL())
+		memory.SetNegativeTTLs(cfg.GetNotFoundTTL(), cfg.GetPrivateTTL())
+		memory.SetCareerTTL(cfg.GetCareerIDTTL())
+	}
+
+	// Initialize Redis if enabled. It doesn't have to be up yet, the cache
+	// falls back to memory and reconnects in the background.
+	var rc *cache.RedisCache
 	if cfg.Redis.Enabled {
-		cache, err := NewRedisCache(
-			cfg.Redis.Host,
-			cfg.Redis.Port,
-			cfg.Redis.Password,
-			cfg.Redis.DB,
-			cfg.GetCacheTTL(),
-		)
-		if err != nil {
-			log.Printf("Warning: Failed to connect to Redis: %v", err)
-			log.Printf("Continuing without cache...")
-		} else {
-			redisCache = cache
-			log.Printf("Redis cache enabled (TTL: %s)", cfg.Redis.CacheTTL)
+		var err error
+		if rc, err = cfg.RedisCache(); err != nil {
+			log.Fatalf("Invalid Redis configuration: %v", err)
+		}
+
+		var primary cache.Store = rc
+		if l1TTL := cfg.GetL1TTL(); l1TTL > 0 {
+			primary = cache.NewTieredStore(rc, cfg.Redis.L1MaxEntries, l1TTL)
+			log.Printf("L1 cache enabled (TTL: %s, max %d entries)", l1TTL, cfg.Redis.L1MaxEntries)
 		}
+
+		var fallback cache.Store
+		if memory != nil {
+			fallback = memory
+		}
+		rs := cache.NewResilientStore(rc, primary, fallback, cfg.GetReconnectMaxBackoff())
+		statsCache = rs
+		log.Printf("Redis cache enabled at %s (TTL: %s, state: %s)",
+			cfg.RedisOptions(), cfg.Redis.CacheTTL, rs.State().State)
 	} else {
 		log.Printf("Redis cache disabled")
+		if memory != nil {
+			statsCache = memory
+			log.Printf("In-memory cache enabled (TTL: %s, max %d entries)", cfg.Redis.CacheTTL, cfg.Memory.MaxEntries)
+		} else {
+			log.Printf("Continuing without cache...")
+		}
+	}
+
+	// Limit the requests sent to Blizzard, together with the scraper when
+	// both share a Redis
+	limiterCfg := cfg.LimiterConfig()
+	if rc != nil && cfg.Upstream.Shared && limiterCfg.Rate > 0 {
+		limiterCfg.Shared = rc.RateBudget(limiterCfg.Rate)
 	}
+	ovrClient = ovrstat.NewClient(ovrstat.WithLimiter(ovrstat.NewLimiter(limiterCfg)))
+	log.Printf("Upstream limit: %g req/s (burst %d), %d in flight",
+		cfg.Upstream.Rate, cfg.Upstream.Burst, cfg.Upstream.MaxInFlight)
+
+	configureServing(cfg)
+
+	// Refresh players in the background through a bounded queue, shared
+	// with the scraper when it is in Redis
+	if rc != nil {
+		scraperRuns = rc.ScraperRuns()
+	}
+
+	// Record the lookups of players, the scraper refreshes the popular ones
+	// first and leaves out the idle ones
+	if rc != nil {
+		popularity = rc.Popularity()
+		go popularity.Run(context.Background(), accessFlushInterval)
+	}
+	if statsCache != nil {
+		refreshQueue = cfg.RefreshQueue(rc)
+		if cfg.Refresh.Workers > 0 {
+			// Periodic jobs in a shared queue are the scraper's, it paces them
+			go cache.RunRefreshWorkers(context.Background(), refreshQueue, cache.PriorityOnDemand, cfg.Refresh.Workers, refreshPlayer)
+		}
+		backend := "memory"
+		if _, ok := refreshQueue.(*cache.RedisQueue); ok {
+			backend = "redis"
+		}
+		log.Printf("Refresh queue: %s (max %d jobs), %d workers", backend, cfg.Refresh.MaxLength, cfg.Refresh.Workers)
+	}
+	extendOnFailure = cfg.GetScraperExtendOnFailure()
 
 	// Set API timeout
 	apiTimeout = cfg.GetAPITimeout()
@@ -148,6 +211,9 @@ func Echo() *echo.Echo {
 	// Handle stats API requests
 	e.GET("/stats/:platform/:tag/profile", statsProfile)
 	e.GET("/stats/:platform/:tag/complete", statsComplete)
+	e.GET("/stats/:platform/:tag/heroes/:hero", statsHero)
+	e.GET("/stats/career/:careerId/:platform/profile", statsCareerProfile)
+	e.GET("/stats/career/:careerId/:platform/complete", statsCareerComplete)
 
 	// Handle news requests
 	e.GET("/news", listNews)
@@ -162,7 +228,14 @@ func Echo() *echo.Echo {
 	admin := e.Group("/admin", adminAuth)
 	admin.POST("/cache/flush", adminFlushCache)
 	admin.POST("/scraper/trigger", adminTriggerScraper)
+	admin.GET("/scraper/runs/:id", adminScraperRun)
 	admin.GET("/cache/stats", adminCacheStats)
+	admin.GET("/cache/negative", adminNegativeEntries)
+	admin.DELETE("/cache/negative", adminPurgeNegative)
+	admin.GET("/cache/keys", adminListKeys)
+	admin.GET("/cache/:platform/:tag", adminInspectPlayer)
+	admin.DELETE("/cache/:platform/:tag", adminDeletePlayer)
+	admin.POST("/cache/:platform/:tag/refresh", adminRefreshPlayer)
 
 	// Admin News endpoints
 	admin.POST("/news", adminAddNews)
@@ -187,6 +260,15 @@ func Echo() *echo.Echo {
 		return c.HTMLBlob(http.StatusOK, data)
 	})
 
+	// Cache browser page
+	e.GET("/admin/cache", func(c echo.Context) error {
+		data, err := staticFS.ReadFile("static/admin-cache.html")
+		if err != nil {
+			return c.String(http.StatusNotFound, "Admin page not found")
+		}
+		return c.HTMLBlob(http.StatusOK, data)
+	})
+
 	return e
 }
 
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/Domekologe/ow-api/config"
+)
+
+// servingPolicy decides whether a request is answered from the cache or by a
+// live lookup
+type servingPolicy string
+
+const (
+	// policyLiveFirst always scrapes live and uses the cache only when
+	// Blizzard doesn't answer in time
+	policyLiveFirst servingPolicy = "live-first"
+
+	// policyCacheFirst serves fresh cached stats and scrapes live otherwise
+	policyCacheFirst servingPolicy = "cache-first"
+
+	// policyStaleWhileRevalidate serves fresh cached stats, serves stale ones
+	// while refreshing them in the background and scrapes live only when
+	// nothing usable is cached
+	policyStaleWhileRevalidate servingPolicy = "stale-while-revalidate"
+)
+
+// servingConfig holds the policy of every stats endpoint
+type servingConfig struct {
+	complete servingPolicy
+	profile  servingPolicy
+	hero     servingPolicy
+	freshFor time.Duration
+	maxStale time.Duration
+}
+
+// serving is the active serving configuration
+var serving = servingConfig{
+	complete: policyLiveFirst,
+	profile:  policyLiveFirst,
+	hero:     policyStaleWhileRevalidate,
+	freshFor: 5 * time.Minute,
+	maxStale: 24 * time.Hour,
+}
+
+// parsePolicy returns the policy named s, or def if s names none
+func parsePolicy(endpoint, s string, def servingPolicy) servingPolicy {
+	switch p := servingPolicy(s); p {
+	case policyLiveFirst, policyCacheFirst, policyStaleWhileRevalidate:
+		return p
+	}
+	log.Printf("Warning: Invalid serving policy '%s' for %s, using %s", s, endpoint, def)
+	return def
+}
+
+// configureServing applies the serving section of the configuration
+func configureServing(cfg *config.Config) {
+	serving = servingConfig{
+		complete: parsePolicy("complete", cfg.Serving.Complete, policyLiveFirst),
+		profile:  parsePolicy("profile", cfg.Serving.Profile, policyLiveFirst),
+		hero:     parsePolicy("hero", cfg.Serving.Hero, policyStaleWhileRevalidate),
+		freshFor: cfg.GetServingFreshFor(),
+		maxStale: cfg.GetServingMaxStale(),
+	}
+	log.Printf("Serving policies: complete=%s, profile=%s, hero=%s (fresh for %s, max stale %s)",
+		serving.complete, serving.profile, serving.hero, serving.freshFor, serving.maxStale)
+}
+
+// serveCached decides whether cached stats fetched at fetchedAt answer a
+// request under policy, and whether they should be refreshed in the
+// background
+func serveCached(policy servingPolicy, fetchedAt time.Time) (serve, revalidate bool) {
+	age := time.Since(fetchedAt)
+	switch policy {
+	case policyCacheFirst:
+		return age <= serving.freshFor, false
+	case policyStaleWhileRevalidate:
+		if age <= serving.freshFor {
+			return true, false
+		}
+		return age <= serving.maxStale, age <= serving.maxStale
+	}
+	return false, false
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServeCached(t *testing.T) {
+	prev := serving
+	serving.freshFor = time.Minute
+	serving.maxStale = time.Hour
+	t.Cleanup(func() { serving = prev })
+
+	tests := []struct {
+		policy     servingPolicy
+		age        time.Duration
+		serve      bool
+		revalidate bool
+	}{
+		{policyLiveFirst, 0, false, false},
+		{policyCacheFirst, 30 * time.Second, true, false},
+		{policyCacheFirst, 2 * time.Minute, false, false},
+		{policyStaleWhileRevalidate, 30 * time.Second, true, false},
+		{policyStaleWhileRevalidate, 30 * time.Minute, true, true},
+		{policyStaleWhileRevalidate, 2 * time.Hour, false, false},
+	}
+	for _, tt := range tests {
+		serve, revalidate := serveCached(tt.policy, time.Now().Add(-tt.age))
+		if serve != tt.serve || revalidate != tt.revalidate {
+			t.Errorf("serveCached(%s, age %s) = %v, %v; want %v, %v",
+				tt.policy, tt.age, serve, revalidate, tt.serve, tt.revalidate)
+		}
+	}
+}
@@ -2,237 +2,440 @@ package service
 
 import (
 	"context"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/Domekologe/ow-api/cache"
 	"github.com/Domekologe/ow-api/ovrstat"
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
 )
 
 var (
-	redisCache *RedisCache
+	// statsCache is Redis, or the in-memory store when Redis is off. It is
+	// nil only when both are disabled.
+	statsCache cache.Store
 	apiTimeout time.Duration
+
+	// ovrClient performs all upstream lookups for the service
+	ovrClient = ovrstat.NewClient()
 )
 
-// statsWithTimeout performs a stats lookup with a timeout
-func statsWithTimeout(platform, tag string, timeout time.Duration) (*ovrstat.PlayerStats, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	resultChan := make(chan *ovrstat.PlayerStats, 1)
-	errChan := make(chan error, 1)
-
-	go func() {
-		// Recover from any panic in the scraper so a single malformed
-		// profile (e.g. a Blizzard HTML format change) returns an error
-		// instead of crashing the whole process.
-		defer func() {
-			if r := recover(); r != nil {
-				errChan <- errors.Errorf("panic during stats scrape: %v", r)
-			}
-		}()
+// careerResult holds both views of a player built from one career page
+type careerResult struct {
+	Stats   *ovrstat.PlayerStats
+	Profile *ovrstat.PlayerStatsProfile
+}
 
-		stats, err := ovrstat.Stats(platform, tag)
-		if err != nil {
-			errChan <- err
-			return
+// fetchCareer downloads the career page of a player once and builds both the
+// complete and the profile stats from it. A cached career ID skips resolving
+// the tag, unless the page it points to now shows someone else.
+func fetchCareer(ctx context.Context, id ovrstat.PlayerID) (*careerResult, error) {
+	var page *ovrstat.CareerPage
+	var err error
+	if careerID := cachedCareerID(id); careerID != "" {
+		page, err = ovrClient.CareerPageByIDContext(ctx, careerID, id.Tag())
+		if err == nil && page.Name() != "" && !strings.EqualFold(page.Name(), id.Name) {
+			// The player was renamed, the tag may belong to someone else now
+			noteCareerName(careerID, page.Name())
+			page = nil
+		} else if errors.Is(err, ovrstat.ErrPlayerNotFound) {
+			page, err = nil, nil
 		}
-		resultChan <- stats
-	}()
+	}
+	if page == nil && err == nil {
+		page, err = ovrClient.CareerPageContext(ctx, id.Tag())
+	}
+	if err != nil {
+		return nil, err
+	}
+	rememberCareer(id, page)
+	return careerStats(page, id.Platform)
+}
 
-	select {
-	case stats := <-resultChan:
-		return stats, nil
-	case err := <-errChan:
+// careerStats builds both views of platform from a career page
+func careerStats(page *ovrstat.CareerPage, platform string) (*careerResult, error) {
+	stats, err := page.Stats(platform)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := page.ProfileStats(platform)
+	if err != nil {
 		return nil, err
-	case <-ctx.Done():
-		return nil, errors.New("request timeout")
 	}
+	return &careerResult{Stats: stats, Profile: profile}, nil
 }
 
-// profileStatsWithTimeout performs a profile stats lookup with a timeout
-func profileStatsWithTimeout(platform, tag string, timeout time.Duration) (*ovrstat.PlayerStatsProfile, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+// careerWithTimeout performs a career lookup with a timeout. Concurrent
+// lookups of the same player share one scrape, shared reports whether this
+// one joined a scrape started by another request. The upstream requests are
+// cancelled once the timeout of the request that started them expires.
+func careerWithTimeout(id ovrstat.PlayerID, timeout time.Duration) (res *careerResult, shared bool, err error) {
+	return lookupWithTimeout(flightKey(flightLive, id), timeout, func(ctx context.Context) (*careerResult, error) {
+		return fetchCareer(ctx, id)
+	})
+}
 
-	resultChan := make(chan *ovrstat.PlayerStatsProfile, 1)
-	errChan := make(chan error, 1)
+// lookupWithTimeout runs fetch as the lookup identified by key, or joins it
+// if it is already running, and waits for it at most timeout
+func lookupWithTimeout(key string, timeout time.Duration, fetch func(ctx context.Context) (*careerResult, error)) (res *careerResult, shared bool, err error) {
+	f, leader := flights.join(key)
+	if leader {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
 
-	go func() {
-		// Recover from any panic in the scraper so a single malformed
-		// profile (e.g. a Blizzard HTML format change) returns an error
-		// instead of crashing the whole process.
-		defer func() {
-			if r := recover(); r != nil {
-				errChan <- errors.Errorf("panic during profile stats scrape: %v", r)
-			}
+			var res *careerResult
+			var err error
+			defer func() {
+				// Recover from any panic in the scraper so a single malformed
+				// profile (e.g. a Blizzard HTML format change) returns an error
+				// instead of crashing the whole process.
+				if r := recover(); r != nil {
+					res, err = nil, errors.Errorf("panic during stats scrape: %v", r)
+				}
+				// A cancelled upstream request surfaces as an error of its own,
+				// report it as the timeout it is
+				if err != nil && ctx.Err() != nil {
+					err = errRequestTimeout
+				}
+				flights.finish(key, f, res, err)
+			}()
+
+			res, err = fetch(ctx)
 		}()
+	}
 
-		stats, err := ovrstat.ProfileStats(platform, tag)
-		if err != nil {
-			errChan <- err
-			return
-		}
-		resultChan <- stats
-	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
 	select {
-	case stats := <-resultChan:
-		return stats, nil
-	case err := <-errChan:
-		return nil, err
-	case <-ctx.Done():
-		return nil, errors.New("request timeout")
+	case <-f.done:
+		return f.res, !leader, f.err
+	case <-timer.C:
+		return nil, !leader, errRequestTimeout
+	}
+}
+
+// liveTimeout is how long a live lookup may take. Without a cache to fall
+// back on, e.g. while Redis is down and memory is disabled, it waits longer.
+func liveTimeout() time.Duration {
+	if statsCache == nil {
+		return 30 * time.Second
+	}
+	if s, ok := statsCache.(interface{ Available() bool }); ok && !s.Available() {
+		return 30 * time.Second
+	}
+	return apiTimeout
+}
+
+// cacheCareer stores both cache entries of a player, so a lookup on either
+// endpoint also refreshes the other one
+func cacheCareer(id ovrstat.PlayerID, res *careerResult) error {
+	if err := statsCache.Set(id.Platform, id.Key(), res.Stats); err != nil {
+		return err
+	}
+	return statsCache.SetProfile(id.Platform, id.Key(), res.Profile)
+}
+
+// knownNotFound reports whether the player was recently not found, so
+// Blizzard isn't asked again until the not-found entry expires
+func knownNotFound(id ovrstat.PlayerID) bool {
+	if statsCache == nil {
+		return false
+	}
+	kind, _, err := statsCache.Negative(id.Platform, id.Key())
+	return err == nil && kind == cache.NegativeNotFound
+}
+
+// rememberNotFound caches that a player doesn't exist
+func rememberNotFound(id ovrstat.PlayerID) {
+	if statsCache == nil {
+		return
+	}
+	if err := statsCache.SetNotFound(id.Platform, id.Key()); err != nil {
+		log.Printf("Failed to cache not found %s: %v", id, err)
+	}
+}
+
+// playerID parses the player of a request from its platform and tag
+// parameters. Invalid ones are rejected before anything is looked up.
+func playerID(c echo.Context) (ovrstat.PlayerID, error) {
+	id, err := ovrstat.ParsePlayerID(c.Param("platform"), c.Param("tag"))
+	if err != nil {
+		return id, newErr(http.StatusBadRequest, err)
+	}
+	return id, nil
+}
+
+// responseSchema reads the requested response schema from the ?schema= query
+// parameter. Clients that don't pass it keep getting SchemaV1.
+func responseSchema(c echo.Context) (int, error) {
+	switch c.QueryParam("schema") {
+	case "", "1":
+		return ovrstat.SchemaV1, nil
+	case "2":
+		return ovrstat.SchemaV2, nil
 	}
+	return 0, codedErr(http.StatusBadRequest, "invalid_schema", "Unsupported schema version, use 1 or 2")
+}
+
+// withSchema converts stats into the requested response schema
+func withSchema(stats *ovrstat.PlayerStats, schema int) *ovrstat.PlayerStats {
+	if schema == ovrstat.SchemaV2 {
+		return stats.Normalized()
+	}
+	return stats
 }
 
 // stats handles retrieving and serving Overwatch stats in JSON
 func statsComplete(c echo.Context) error {
-	platform := c.Param("platform")
-	tag := c.Param("tag")
-	clientIP := c.RealIP()
+	id, err := playerID(c)
+	if err != nil {
+		return err
+	}
 
+	schema, err := responseSchema(c)
+	if err != nil {
+		return err
+	}
+	return serveComplete(c, id, schema)
+}
+
+// serveComplete serves the complete stats of a player
+func serveComplete(c echo.Context, id ovrstat.PlayerID, schema int) error {
 	// Log request
-	logRequest(platform, tag, clientIP)
+	logRequest(id, c.RealIP())
+	recordAccess(id)
+
+	stats, lk, err := completeStats(id, serving.complete)
+	setCoalesced(c, lk.shared)
+	if err != nil {
+		return err
+	}
+
+	applySeasonResetsIfConfigured(stats)
+	return sendStats(c, lk, withSchema(stats, schema))
+}
 
-	// Determine timeout based on Redis availability
-	timeout := apiTimeout
-	if redisCache == nil {
-		timeout = 30 * time.Second
+// completeStats looks up the complete stats of a player, from the cache or
+// live as policy says. A live lookup falls back to the cache when Blizzard
+// doesn't answer in time. The returned lookup tells where the stats came
+// from. Returned errors are ready to be sent to the client.
+func completeStats(id ovrstat.PlayerID, policy servingPolicy) (*ovrstat.PlayerStats, lookup, error) {
+	if statsCache != nil && policy != policyLiveFirst {
+		cachedStats, fetchedAt, cacheErr := statsCache.GetEntry(id.Platform, id.Key())
+		if cacheErr == nil && cachedStats != nil {
+			if serve, revalidate := serveCached(policy, fetchedAt); serve {
+				if revalidate {
+					logResponse(id, "Served stale from cache, background scraper triggered")
+					triggerScraperUpdate(id)
+				} else {
+					logResponse(id, "Served from cache")
+				}
+				return cachedStats, cachedLookup(fetchedAt, false), nil
+			}
+		}
+	}
+
+	if knownNotFound(id) {
+		logResponse(id, "Player not found (cached)")
+		return nil, lookup{}, codedErr(http.StatusNotFound, "player_not_found", "Player not found!")
 	}
 
 	// Try live scraping first with timeout
-	stats, err := statsWithTimeout(platform, tag, timeout)
+	res, shared, err := careerWithTimeout(id, liveTimeout())
 
 	if err != nil {
 		// On timeout, try to use cache data as fallback
-		if err.Error() == "request timeout" {
-			if redisCache != nil {
-				cachedStats, cacheErr := redisCache.Get(platform, tag)
+		if errors.Is(err, errRequestTimeout) {
+			if statsCache != nil {
+				cachedStats, fetchedAt, cacheErr := statsCache.GetEntry(id.Platform, id.Key())
 				if cacheErr == nil && cachedStats != nil {
 					// Trigger background scraper to refresh
-					logResponse(platform, tag, "Timeout - Serving from cache, background scraper triggered")
-					triggerScraperUpdate(platform, tag)
-					applySeasonResetsIfConfigured(cachedStats)
-					return c.JSON(http.StatusOK, cachedStats)
+					logResponse(id, "Timeout - Serving from cache, background scraper triggered")
+					triggerScraperUpdate(id)
+					return cachedStats, cachedLookup(fetchedAt, shared), nil
 				}
-				logResponse(platform, tag, "Timeout - Sent to background scraper")
+				logResponse(id, "Timeout - Sent to background scraper")
 				// Trigger scraper even without cache
-				triggerScraperUpdate(platform, tag)
-				return newErr(http.StatusGatewayTimeout, "Request timeout - Data will be scraped in background")
+				triggerScraperUpdate(id)
+				return nil, lookup{shared: shared}, codedErr(http.StatusGatewayTimeout, "timeout", "Request timeout - Data will be scraped in background")
 			}
-			// If Redis is not enabled, we can't background scrape, so just return timeout
-			logResponse(platform, tag, "Timeout - No cache available")
-			return newErr(http.StatusGatewayTimeout, "Request timeout")
+			// Without a cache, we can't background scrape, so just return timeout
+			logResponse(id, "Timeout - No cache available")
+			return nil, lookup{shared: shared}, codedErr(http.StatusGatewayTimeout, "timeout", "Request timeout")
+		}
+
+		// Our own upstream limiter refused the request, Blizzard wasn't asked
+		if errors.Is(err, ovrstat.ErrRateLimitedLocally) {
+			if statsCache != nil {
+				cachedStats, fetchedAt, cacheErr := statsCache.GetEntry(id.Platform, id.Key())
+				if cacheErr == nil && cachedStats != nil {
+					logResponse(id, "Rate limited - Serving from cache")
+					return cachedStats, cachedLookup(fetchedAt, shared), nil
+				}
+			}
+			logResponse(id, "Rate limited - No cache available")
+			return nil, lookup{shared: shared}, codedErr(http.StatusTooManyRequests, "rate_limited", "Too many requests - Please try again later")
 		}
 
 		// Handle other errors
-		if err == ovrstat.ErrPlayerNotFound {
-			logResponse(platform, tag, "Player not found")
-			return newErr(http.StatusNotFound, "Player not found!")
+		if errors.Is(err, ovrstat.ErrPlayerNotFound) {
+			logResponse(id, "Player not found")
+			rememberNotFound(id)
+			return nil, lookup{shared: shared}, codedErr(http.StatusNotFound, "player_not_found", "Player not found!")
 		}
-		logResponse(platform, tag, "Error: "+err.Error())
-		return newErr(http.StatusInternalServerError,
+		logResponse(id, "Error: "+err.Error())
+		return nil, lookup{shared: shared}, newErr(http.StatusInternalServerError,
 			errors.Wrap(err, "Failed to retrieve player stats"))
 	}
 
+	// The result may be shared with coalesced requests, season resets are
+	// applied to a copy
+	statsCopy := *res.Stats
+	stats := &statsCopy
+
 	// Check if profile is private
 	if stats.Private {
-		logResponse(platform, tag, "Profile is private")
+		logResponse(id, "Profile is private")
 		// Still cache private profiles
-		if redisCache != nil {
-			redisCache.Set(platform, tag, stats)
+		if statsCache != nil {
+			cacheCareer(id, res)
 		}
-		applySeasonResetsIfConfigured(stats)
-		return c.JSON(http.StatusOK, stats)
+		return stats, liveLookup(shared), nil
 	}
 
 	// Store in cache for future requests
-	if redisCache != nil {
-		if err := redisCache.Set(platform, tag, stats); err == nil {
-			logResponse(platform, tag, "Player found - Cached")
+	if statsCache != nil {
+		if err := cacheCareer(id, res); err == nil {
+			logResponse(id, "Player found - Cached")
 		} else {
-			logResponse(platform, tag, "Player found - Cache failed")
+			logResponse(id, "Player found - Cache failed")
 		}
 	} else {
-		logResponse(platform, tag, "Player found")
+		logResponse(id, "Player found")
 	}
 
-	applySeasonResetsIfConfigured(stats)
-	return c.JSON(http.StatusOK, stats)
+	return stats, liveLookup(shared), nil
 }
 
 func statsProfile(c echo.Context) error {
-	platform := c.Param("platform")
-	tag := c.Param("tag")
-	clientIP := c.RealIP()
+	id, err := playerID(c)
+	if err != nil {
+		return err
+	}
+	return serveProfile(c, id)
+}
 
+// serveProfile serves the profile stats of a player, from the cache or live
+// as the serving policy says
+func serveProfile(c echo.Context, id ovrstat.PlayerID) error {
 	// Log request
-	logRequest(platform, tag, clientIP)
+	logRequest(id, c.RealIP())
+	recordAccess(id)
 
-	// Determine timeout based on Redis availability
-	timeout := apiTimeout
-	if redisCache == nil {
-		timeout = 30 * time.Second
+	if statsCache != nil && serving.profile != policyLiveFirst {
+		cachedStats, fetchedAt, cacheErr := statsCache.GetProfileEntry(id.Platform, id.Key())
+		if cacheErr == nil && cachedStats != nil {
+			if serve, revalidate := serveCached(serving.profile, fetchedAt); serve {
+				if revalidate {
+					logResponse(id, "Served stale from cache (profile), background scraper triggered")
+					triggerScraperUpdate(id)
+				} else {
+					logResponse(id, "Served from cache (profile)")
+				}
+				setCoalesced(c, false)
+				applySeasonResetsProfileIfConfigured(cachedStats)
+				return sendStats(c, cachedLookup(fetchedAt, false), cachedStats)
+			}
+		}
+	}
+
+	if knownNotFound(id) {
+		logResponse(id, "Player not found (cached)")
+		return codedErr(http.StatusNotFound, "player_not_found", "Player not found!")
 	}
 
 	// Try live scraping first with timeout
-	stats, err := profileStatsWithTimeout(platform, tag, timeout)
+	res, shared, err := careerWithTimeout(id, liveTimeout())
+	setCoalesced(c, shared)
 	if err != nil {
 		// On timeout, try to use cache data as fallback
-		if err.Error() == "request timeout" {
-			if redisCache != nil {
-				cachedStats, cacheErr := redisCache.GetProfile(platform, tag)
+		if errors.Is(err, errRequestTimeout) {
+			if statsCache != nil {
+				cachedStats, fetchedAt, cacheErr := statsCache.GetProfileEntry(id.Platform, id.Key())
 				if cacheErr == nil && cachedStats != nil {
 					// Trigger background scraper to refresh
-					logResponse(platform, tag, "Timeout - Serving from cache (profile), background scraper triggered")
-					triggerScraperUpdateProfile(platform, tag)
+					logResponse(id, "Timeout - Serving from cache (profile), background scraper triggered")
+					triggerScraperUpdate(id)
 					applySeasonResetsProfileIfConfigured(cachedStats)
-					return c.JSON(http.StatusOK, cachedStats)
+					return sendStats(c, cachedLookup(fetchedAt, shared), cachedStats)
 				}
-				logResponse(platform, tag, "Timeout - Sent to background scraper (profile)")
+				logResponse(id, "Timeout - Sent to background scraper (profile)")
 				// Trigger scraper even without cache
-				triggerScraperUpdateProfile(platform, tag)
-				return newErr(http.StatusGatewayTimeout, "Request timeout - Data will be scraped in background")
+				triggerScraperUpdate(id)
+				return codedErr(http.StatusGatewayTimeout, "timeout", "Request timeout - Data will be scraped in background")
+			}
+			// Without a cache, we can't background scrape, so just return timeout
+			logResponse(id, "Timeout - No cache available")
+			return codedErr(http.StatusGatewayTimeout, "timeout", "Request timeout")
+		}
+
+		// Our own upstream limiter refused the request, Blizzard wasn't asked
+		if errors.Is(err, ovrstat.ErrRateLimitedLocally) {
+			if statsCache != nil {
+				cachedStats, fetchedAt, cacheErr := statsCache.GetProfileEntry(id.Platform, id.Key())
+				if cacheErr == nil && cachedStats != nil {
+					logResponse(id, "Rate limited - Serving from cache (profile)")
+					applySeasonResetsProfileIfConfigured(cachedStats)
+					return sendStats(c, cachedLookup(fetchedAt, shared), cachedStats)
+				}
 			}
-			// If Redis is not enabled, we can't background scrape, so just return timeout
-			logResponse(platform, tag, "Timeout - No cache available")
-			return newErr(http.StatusGatewayTimeout, "Request timeout")
+			logResponse(id, "Rate limited - No cache available")
+			return codedErr(http.StatusTooManyRequests, "rate_limited", "Too many requests - Please try again later")
 		}
 
 		// Handle other errors
-		if err == ovrstat.ErrPlayerNotFound {
-			logResponse(platform, tag, "Player not found")
-			return newErr(http.StatusNotFound, "Player not found!")
+		if errors.Is(err, ovrstat.ErrPlayerNotFound) {
+			logResponse(id, "Player not found")
+			rememberNotFound(id)
+			return codedErr(http.StatusNotFound, "player_not_found", "Player not found!")
 		}
-		logResponse(platform, tag, "Error: "+err.Error())
+		logResponse(id, "Error: "+err.Error())
 		return newErr(http.StatusInternalServerError,
 			errors.Wrap(err, "Failed to retrieve player stats"))
 	}
 
+	// The result may be shared with coalesced requests, season resets are
+	// applied to a copy
+	statsCopy := *res.Profile
+	stats := &statsCopy
+
 	// Check if profile is private
 	if stats.Private {
-		logResponse(platform, tag, "Profile is private")
+		logResponse(id, "Profile is private")
 		// Still cache private profiles
-		if redisCache != nil {
-			redisCache.SetProfile(platform, tag, stats)
+		if statsCache != nil {
+			cacheCareer(id, res)
 		}
 		applySeasonResetsProfileIfConfigured(stats)
-		return c.JSON(http.StatusOK, stats)
+		return sendStats(c, liveLookup(shared), stats)
 	}
 
 	// Store in cache for future requests
-	if redisCache != nil {
-		if err := redisCache.SetProfile(platform, tag, stats); err == nil {
-			logResponse(platform, tag, "Player found (profile) - Cached")
+	if statsCache != nil {
+		if err := cacheCareer(id, res); err == nil {
+			logResponse(id, "Player found (profile) - Cached")
 		} else {
-			logResponse(platform, tag, "Player found (profile) - Cache failed")
+			logResponse(id, "Player found (profile) - Cache failed")
 		}
 	} else {
-		logResponse(platform, tag, "Player found (profile)")
+		logResponse(id, "Player found (profile)")
 	}
 
 	applySeasonResetsProfileIfConfigured(stats)
-	return c.JSON(http.StatusOK, stats)
+	return sendStats(c, liveLookup(shared), stats)
 }
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Domekologe/ow-api/cache"
+	"github.com/Domekologe/ow-api/ovrstat"
+	"github.com/labstack/echo/v4"
+)
+
+func TestStatsInvalidTag(t *testing.T) {
+	srv, hits := newFakeBlizzard(t, 0)
+	prev := ovrClient
+	ovrClient = ovrstat.NewClient(ovrstat.WithBaseURL(srv.URL))
+	t.Cleanup(func() { ovrClient = prev })
+
+	e := echo.New()
+	e.GET("/stats/:platform/:tag/complete", statsComplete)
+
+	req := httptest.NewRequest(http.MethodGet, "/stats/pc/Foo:1234/complete", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d; want 400", rec.Code)
+	}
+	if got := *hits; got != 0 {
+		t.Errorf("career page fetched %d times; want 0", got)
+	}
+}
+
+func TestStatsUnknownHero(t *testing.T) {
+	srv, hits := newFakeBlizzard(t, 0)
+	prev := ovrClient
+	ovrClient = ovrstat.NewClient(ovrstat.WithBaseURL(srv.URL))
+	t.Cleanup(func() { ovrClient = prev })
+
+	e := echo.New()
+	e.GET("/stats/:platform/:tag/heroes/:hero", statsHero)
+
+	req := httptest.NewRequest(http.MethodGet, "/stats/pc/Foo-1234/heroes/gengi", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d; want 404", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"genji"`) {
+		t.Errorf("body = %s; want all known heroes", rec.Body)
+	}
+	if got := *hits; got != 0 {
+		t.Errorf("career page fetched %d times; want 0", got)
+	}
+}
+
+func TestStatsByCareerID(t *testing.T) {
+	srv, hits := newFakeBlizzard(t, 0)
+	prevClient, prevCache, prevTimeout := ovrClient, statsCache, apiTimeout
+	ovrClient = ovrstat.NewClient(ovrstat.WithBaseURL(srv.URL))
+	m := cache.NewMemoryStore(10, time.Hour)
+	m.SetCareerTTL(time.Hour)
+	statsCache = m
+	apiTimeout = 5 * time.Second
+	t.Cleanup(func() { ovrClient, statsCache, apiTimeout = prevClient, prevCache, prevTimeout })
+
+	// A lookup by tag remembers the career ID
+	id, _ := ovrstat.ParsePlayerID("pc", "Foo#1234")
+	res, err := fetchCareer(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stats.CareerID != "Foo-1234|abc123" {
+		t.Errorf("CareerID = %q; want %q", res.Stats.CareerID, "Foo-1234|abc123")
+	}
+	if got, _ := m.CareerID("foo-1234"); got != "Foo-1234|abc123" {
+		t.Errorf("cached career ID = %q; want %q", got, "Foo-1234|abc123")
+	}
+
+	e := echo.New()
+	e.GET("/stats/career/:careerId/:platform/profile", statsCareerProfile)
+
+	req := httptest.NewRequest(http.MethodGet, "/stats/career/Foo-1234%7Cabc123/pc/profile", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; want 200: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), `"careerId":"Foo-1234|abc123"`) {
+		t.Errorf("body = %s; want the career ID", rec.Body)
+	}
+	if got := *hits; got != 2 {
+		t.Errorf("career page fetched %d times; want 2", got)
+	}
+
+	// The career ID maps back to the tag, so its lookups share the cache
+	prevServing := serving
+	serving.profile = policyCacheFirst
+	t.Cleanup(func() { serving = prevServing })
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats/career/Foo-1234%7Cabc123/pc/profile", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Data-Source") != sourceCache {
+		t.Errorf("status = %d, source = %q; want 200 from the cache", rec.Code, rec.Header().Get("X-Data-Source"))
+	}
+	if got := *hits; got != 2 {
+		t.Errorf("career page fetched %d times; want 2", got)
+	}
+}